@@ -5,7 +5,6 @@ go 1.13
 require (
 	github.com/gorilla/mux v1.7.3
 	github.com/kennygrant/sanitize v1.2.4
-	github.com/mikeflynn/go-alexa v0.0.0-20191016174603-1ffcf485965f
 	github.com/urfave/negroni v1.0.0
 	golang.org/x/net v0.0.0-20200114155413-6afb5195e5aa // indirect
 	gopkg.in/mgo.v2 v2.0.0-20190816093944-a6b53ec6cb22
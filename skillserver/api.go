@@ -0,0 +1,145 @@
+package skillserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIError is returned by the Alexa service API clients when a call is answered with
+// an unexpected status code.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("alexa api responded with status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("alexa api responded with status %d: %s", e.StatusCode, e.Message)
+}
+
+// PermissionError is returned by the Alexa service API clients when the end user has not
+// granted the skill the permission required for the call. Skills should respond with an
+// `AskForPermissionsConsentCard` when they receive this error.
+type PermissionError struct {
+	Message string
+}
+
+func (e *PermissionError) Error() string {
+	if e.Message == "" {
+		return "permission required for alexa api call"
+	}
+
+	return "permission required for alexa api call: " + e.Message
+}
+
+// GetAPIAccessToken returns the token used to authorize calls against the Alexa service APIs, e.g. by
+// `NewListsClient`. The token is only valid for a short time, at most for the duration of the conversation
+// turn, so it should be read from every request instead of being stored. See `GetAPIAccessTokenExpiry`
+// for when it expires.
+func (r *EchoRequest) GetAPIAccessToken() string {
+	return r.Context.System.APIAccessToken
+}
+
+// GetAPIAccessTokenExpiry returns the expiry time embedded in the API access token. The token is a
+// JWT, its signature is not verified. False is returned if the request carries no token or the token
+// doesn't contain an expiry time, in which case it should only be used for the current request.
+func (r *EchoRequest) GetAPIAccessTokenExpiry() (time.Time, bool) {
+	parts := strings.Split(r.GetAPIAccessToken(), ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp <= 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(claims.Exp), 0), true
+}
+
+// apiClient holds everything needed to make an authorized call against the Alexa service APIs.
+type apiClient struct {
+	endpoint    string
+	accessToken string
+	httpClient  *http.Client
+}
+
+func newAPIClient(r *EchoRequest) apiClient {
+	return apiClient{
+		endpoint:    strings.TrimSuffix(r.Context.System.APIEndpoint, "/"),
+		accessToken: r.Context.System.APIAccessToken,
+		httpClient:  &http.Client{Timeout: time.Second * 5},
+	}
+}
+
+// do sends the request body `in` encoded as JSON to the given path and decodes the response into `out`.
+// Either of them may be nil.
+func (c apiClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("could not encode request: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(respBody, &apiErr)
+
+		if resp.StatusCode == http.StatusForbidden {
+			return &PermissionError{Message: apiErr.Message}
+		}
+
+		return &APIError{StatusCode: resp.StatusCode, Message: apiErr.Message}
+	}
+
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("could not decode response: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package skillserver
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+// Returns an unsigned JWT with the claims, shaped like an API access token.
+func testAccessToken(claims string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"typ":"JWT","alg":"RS256","kid":"1"}`)) + "." + encode([]byte(claims)) + ".signature"
+}
+
+func TestGetAPIAccessTokenExpiry(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		want   time.Time
+		wantOK bool
+	}{
+		{"expiry", testAccessToken(`{"aud":"https://api.amazonalexa.com","iss":"AlexaSkillKit","exp":1549200000}`), time.Unix(1549200000, 0), true},
+		{"no expiry", testAccessToken(`{"aud":"https://api.amazonalexa.com"}`), time.Time{}, false},
+		{"not a JWT", "Atza|token", time.Time{}, false},
+		{"no token", "", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &EchoRequest{}
+			req.Context.System.APIAccessToken = tt.token
+			if req.GetAPIAccessToken() != tt.token {
+				t.Errorf("GetAPIAccessToken() = %q, want %q", req.GetAPIAccessToken(), tt.token)
+			}
+
+			got, ok := req.GetAPIAccessTokenExpiry()
+			if !got.Equal(tt.want) || ok != tt.wantOK {
+				t.Errorf("GetAPIAccessTokenExpiry() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
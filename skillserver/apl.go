@@ -0,0 +1,224 @@
+package skillserver
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+/**
+ * Details about the Alexa Presentation Language (APL) can be found on this page:
+ * https://developer.amazon.com/docs/alexa-presentation-language/apl-render-document-skill-directive.html
+ */
+
+const (
+	// APLRenderDocument is the directive type used to display an APL document on a device with a screen.
+	APLRenderDocument = "Alexa.Presentation.APL.RenderDocument"
+	// APLARenderDocument is the directive type used to play an APL for Audio document.
+	APLARenderDocument = "Alexa.Presentation.APLA.RenderDocument"
+	// APLTRenderDocument is the directive type used to display an APLT document on a character display.
+	APLTRenderDocument = "Alexa.Presentation.APLT.RenderDocument"
+	// APLSendIndexListData is the directive type used to send items of a dynamically loaded list.
+	APLSendIndexListData = "Alexa.Presentation.APL.SendIndexListData"
+	// APLSendTokenListData is the directive type used to send a page of a dynamically loaded list.
+	APLSendTokenListData = "Alexa.Presentation.APL.SendTokenListData"
+	// APLExecuteCommands is the directive type used to run commands on a rendered APL document.
+	APLExecuteCommands = "Alexa.Presentation.APL.ExecuteCommands"
+)
+
+// ErrMissingAPLToken is returned when commands are sent without the token of the document they target.
+var ErrMissingAPLToken = errors.New("token of the rendered document is required")
+
+// APLRenderDocumentDirective instructs the device to render the provided document, filled with the
+// optional data sources. The token identifies the document in later requests and directives.
+// It is used for visual APL, APL for Audio and APLT documents, the target profile is only used by APLT.
+type APLRenderDocumentDirective struct {
+	Type          string          `json:"type"`
+	Token         string          `json:"token,omitempty"`
+	TargetProfile string          `json:"targetProfile,omitempty"`
+	Document      json.RawMessage `json:"document"`
+	Datasources   json.RawMessage `json:"datasources,omitempty"`
+}
+
+// DirectiveType returns the type of the render document directive.
+func (d *APLRenderDocumentDirective) DirectiveType() string {
+	return d.Type
+}
+
+// AddAPLRenderDocumentDirective will add a directive to the response that renders the provided APL document.
+// The datasources may be nil if the document does not bind any data.
+func (r *EchoResponse) AddAPLRenderDocumentDirective(token string, document, datasources json.RawMessage) *EchoResponse {
+	r.Response.Directives = append(r.Response.Directives, &APLRenderDocumentDirective{
+		Type:        APLRenderDocument,
+		Token:       token,
+		Document:    document,
+		Datasources: datasources,
+	})
+
+	return r
+}
+
+// AddAPLRenderDocumentFromURL will add a directive to the response that renders an APL document hosted
+// at the provided location instead of including it in the response. The URL must use https.
+func (r *EchoResponse) AddAPLRenderDocumentFromURL(token, documentURL string) error {
+	if err := requireHTTPS(documentURL); err != nil {
+		return err
+	}
+
+	document, err := json.Marshal(struct {
+		Type string `json:"type"`
+		Src  string `json:"src"`
+	}{"Link", documentURL})
+	if err != nil {
+		return err
+	}
+
+	r.AddAPLRenderDocumentDirective(token, document, nil)
+
+	return nil
+}
+
+// AddAPLARenderDocumentDirective will add a directive to the response that renders the provided APL for Audio
+// document, mixing speech, sound effects and music into the audio played to the end user.
+func (r *EchoResponse) AddAPLARenderDocumentDirective(token string, document, datasources json.RawMessage) *EchoResponse {
+	r.Response.Directives = append(r.Response.Directives, &APLRenderDocumentDirective{
+		Type:        APLARenderDocument,
+		Token:       token,
+		Document:    document,
+		Datasources: datasources,
+	})
+
+	return r
+}
+
+// AddAPLTRenderDocumentDirective will add a directive to the response that renders the provided APLT document
+// on a device with a character display, e.g. an Echo Dot with clock. The target profile is "FOUR_CHARACTER_CLOCK"
+// or "NONE".
+func (r *EchoResponse) AddAPLTRenderDocumentDirective(token string, document, datasources json.RawMessage, targetProfile string) *EchoResponse {
+	r.Response.Directives = append(r.Response.Directives, &APLRenderDocumentDirective{
+		Type:          APLTRenderDocument,
+		Token:         token,
+		TargetProfile: targetProfile,
+		Document:      document,
+		Datasources:   datasources,
+	})
+
+	return r
+}
+
+// APLSendIndexListDataDirective sends a batch of items of a dynamicIndexList data source in answer to
+// an `Alexa.Presentation.APL.LoadIndexListData` request.
+type APLSendIndexListDataDirective struct {
+	Type             string          `json:"type"`
+	CorrelationToken string          `json:"correlationToken,omitempty"`
+	ListID           string          `json:"listId"`
+	ListVersion      int             `json:"listVersion,omitempty"`
+	StartIndex       int             `json:"startIndex"`
+	Items            json.RawMessage `json:"items"`
+}
+
+// DirectiveType returns the type of the send index list data directive.
+func (d *APLSendIndexListDataDirective) DirectiveType() string {
+	return d.Type
+}
+
+// AddSendIndexListDataDirective will add a directive to the response that sends the items of the list, starting
+// at the given index. The correlation token and list ID are taken from the `Alexa.Presentation.APL.LoadIndexListData`
+// request, see `EchoReqBody.CorrelationToken` and `EchoReqBody.ListID`.
+func (r *EchoResponse) AddSendIndexListDataDirective(correlationToken, listID string, startIndex int, items json.RawMessage) *EchoResponse {
+	r.Response.Directives = append(r.Response.Directives, &APLSendIndexListDataDirective{
+		Type:             APLSendIndexListData,
+		CorrelationToken: correlationToken,
+		ListID:           listID,
+		StartIndex:       startIndex,
+		Items:            items,
+	})
+
+	return r
+}
+
+// APLSendTokenListDataDirective sends a page of items of a dynamicTokenList data source in answer to
+// an `Alexa.Presentation.APL.LoadTokenListData` request. NextPageToken is left empty on the last page.
+type APLSendTokenListDataDirective struct {
+	Type             string          `json:"type"`
+	CorrelationToken string          `json:"correlationToken,omitempty"`
+	ListID           string          `json:"listId"`
+	PageToken        string          `json:"pageToken"`
+	NextPageToken    string          `json:"nextPageToken,omitempty"`
+	Items            json.RawMessage `json:"items"`
+}
+
+// DirectiveType returns the type of the send token list data directive.
+func (d *APLSendTokenListDataDirective) DirectiveType() string {
+	return d.Type
+}
+
+// AddSendTokenListDataDirective will add a directive to the response that sends the items of the page with the
+// given token. The correlation token, list ID and page token are taken from the `Alexa.Presentation.APL.LoadTokenListData`
+// request, see `EchoReqBody.CorrelationToken`, `EchoReqBody.ListID` and `EchoReqBody.PageToken`.
+func (r *EchoResponse) AddSendTokenListDataDirective(correlationToken, listID, pageToken string, items json.RawMessage) *EchoResponse {
+	r.Response.Directives = append(r.Response.Directives, &APLSendTokenListDataDirective{
+		Type:             APLSendTokenListData,
+		CorrelationToken: correlationToken,
+		ListID:           listID,
+		PageToken:        pageToken,
+		Items:            items,
+	})
+
+	return r
+}
+
+// APLCommand is a single APL command. The helpers below build the common ones, other commands can be
+// built as a map with the properties described in the APL reference, including "type".
+type APLCommand map[string]interface{}
+
+// SpeakItemCommand reads the speech bound to the component aloud, scrolling it into view.
+func SpeakItemCommand(componentID string) APLCommand {
+	return APLCommand{"type": "SpeakItem", "componentId": componentID}
+}
+
+// ScrollCommand scrolls the component by the given distance, measured in multiples of its height.
+func ScrollCommand(componentID string, distance float64) APLCommand {
+	return APLCommand{"type": "Scroll", "componentId": componentID, "distance": distance}
+}
+
+// SetPageCommand changes the page of a Pager component. The position is "absolute" or "relative".
+func SetPageCommand(componentID, position string, value int) APLCommand {
+	return APLCommand{"type": "SetPage", "componentId": componentID, "position": position, "value": value}
+}
+
+// APLExecuteCommandsDirective runs commands on the document rendered with the same token.
+type APLExecuteCommandsDirective struct {
+	Type     string       `json:"type"`
+	Token    string       `json:"token"`
+	Commands []APLCommand `json:"commands"`
+}
+
+// DirectiveType returns the type of the execute commands directive.
+func (d *APLExecuteCommandsDirective) DirectiveType() string {
+	return d.Type
+}
+
+// AddAPLExecuteCommandsDirective will add a directive to the response that runs the commands on the document
+// currently displayed, which is identified by the token it was rendered with. The document may have been
+// rendered in an earlier response. An error is returned and no directive is added if the token is empty.
+func (r *EchoResponse) AddAPLExecuteCommandsDirective(token string, commands ...APLCommand) error {
+	if token == "" {
+		return ErrMissingAPLToken
+	}
+
+	r.Response.Directives = append(r.Response.Directives, &APLExecuteCommandsDirective{
+		Type:     APLExecuteCommands,
+		Token:    token,
+		Commands: commands,
+	})
+
+	return nil
+}
+
+// GetAPLVisualContext returns the raw JSON of the "Alexa.Presentation.APL" context object, which describes
+// the APL document currently displayed, including its token and the visible components. Comparing the token
+// lets a skill run commands on the displayed document instead of rendering it again. False is returned if
+// the device doesn't display an APL document.
+func (r *EchoRequest) GetAPLVisualContext() (json.RawMessage, bool) {
+	return r.GetContextObject("Alexa.Presentation.APL")
+}
@@ -0,0 +1,166 @@
+package skillserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAddAPLRenderDocumentFromURL(t *testing.T) {
+	resp := NewEchoResponse()
+	if err := resp.AddAPLRenderDocumentFromURL("doc", "https://example.com/document.json"); err != nil {
+		t.Fatalf("AddAPLRenderDocumentFromURL() error = %v", err)
+	}
+
+	checkDirectivesJSON(t, resp, `[{
+		"type": "Alexa.Presentation.APL.RenderDocument",
+		"token": "doc",
+		"document": {"type": "Link", "src": "https://example.com/document.json"}
+	}]`)
+}
+
+func TestAddAPLRenderDocumentFromURLRequiresHTTPS(t *testing.T) {
+	resp := NewEchoResponse()
+	if err := resp.AddAPLRenderDocumentFromURL("doc", "http://example.com/document.json"); !errors.Is(err, ErrInsecureURL) {
+		t.Errorf("AddAPLRenderDocumentFromURL() error = %v, want %v", err, ErrInsecureURL)
+	}
+	if len(resp.Response.Directives) != 0 {
+		t.Errorf("directives = %v, want none", resp.Response.Directives)
+	}
+}
+
+func TestAddAPLRenderDocumentDirective(t *testing.T) {
+	resp := NewEchoResponse().AddAPLRenderDocumentDirective("doc", json.RawMessage(`{"type":"APL","version":"1.4"}`), json.RawMessage(`{"data":{}}`))
+
+	checkDirectivesJSON(t, resp, `[{
+		"type": "Alexa.Presentation.APL.RenderDocument",
+		"token": "doc",
+		"document": {"type": "APL", "version": "1.4"},
+		"datasources": {"data": {}}
+	}]`)
+}
+
+func TestAddAPLARenderDocumentDirective(t *testing.T) {
+	resp := NewEchoResponse().AddAPLARenderDocumentDirective("audio", json.RawMessage(`{"type":"APLA","version":"0.9"}`), nil)
+
+	checkDirectivesJSON(t, resp, `[{
+		"type": "Alexa.Presentation.APLA.RenderDocument",
+		"token": "audio",
+		"document": {"type": "APLA", "version": "0.9"}
+	}]`)
+}
+
+func TestAddAPLTRenderDocumentDirective(t *testing.T) {
+	resp := NewEchoResponse().AddAPLTRenderDocumentDirective("clock", json.RawMessage(`{"type":"APLT","version":"1.0"}`), nil, "FOUR_CHARACTER_CLOCK")
+
+	checkDirectivesJSON(t, resp, `[{
+		"type": "Alexa.Presentation.APLT.RenderDocument",
+		"token": "clock",
+		"targetProfile": "FOUR_CHARACTER_CLOCK",
+		"document": {"type": "APLT", "version": "1.0"}
+	}]`)
+}
+
+func TestLoadIndexListDataRequest(t *testing.T) {
+	req, err := ParseEchoRequest(strings.NewReader(`{"request":{
+		"type": "Alexa.Presentation.APL.LoadIndexListData",
+		"token": "doc",
+		"correlationToken": "correlation",
+		"listId": "list",
+		"startIndex": 20,
+		"count": 10
+	}}`))
+	if err != nil {
+		t.Fatalf("ParseEchoRequest() error = %v", err)
+	}
+
+	if r := req.Request; r.CorrelationToken != "correlation" || r.ListID != "list" || r.StartIndex != 20 || r.Count != 10 {
+		t.Errorf("request = %+v, want the list data request", r)
+	}
+}
+
+func TestAddSendIndexListDataDirective(t *testing.T) {
+	resp := NewEchoResponse().AddSendIndexListDataDirective("correlation", "list", 20, json.RawMessage(`[{"name":"item 20"}]`))
+
+	checkDirectivesJSON(t, resp, `[{
+		"type": "Alexa.Presentation.APL.SendIndexListData",
+		"correlationToken": "correlation",
+		"listId": "list",
+		"startIndex": 20,
+		"items": [{"name": "item 20"}]
+	}]`)
+}
+
+func TestOnAPLLoadTokenListData(t *testing.T) {
+	h := testAppHandler(t, EchoApplication{
+		OnAPLLoadTokenListData: func(req *EchoRequest, resp *EchoResponse) {
+			r := req.Request
+			resp.AddSendTokenListDataDirective(r.CorrelationToken, r.ListID, r.PageToken, json.RawMessage(`[{"name":"item"}]`))
+		},
+	})
+
+	w := postTestEcho(h, testRequestBodyWith("Alexa.Presentation.APL.LoadTokenListData",
+		`"token": "doc", "correlationToken": "correlation", "listId": "list", "pageToken": "page-2"`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	want := `"directives":[{"type":"Alexa.Presentation.APL.SendTokenListData","correlationToken":"correlation","listId":"list","pageToken":"page-2","items":[{"name":"item"}]}]`
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("body = %s, want %s", w.Body.String(), want)
+	}
+}
+
+func TestAddAPLExecuteCommandsDirective(t *testing.T) {
+	resp := NewEchoResponse()
+	if err := resp.AddAPLExecuteCommandsDirective("doc", SpeakItemCommand("headline")); err != nil {
+		t.Fatalf("AddAPLExecuteCommandsDirective() error = %v", err)
+	}
+
+	checkDirectivesJSON(t, resp, `[{
+		"type": "Alexa.Presentation.APL.ExecuteCommands",
+		"token": "doc",
+		"commands": [{"type": "SpeakItem", "componentId": "headline"}]
+	}]`)
+}
+
+func TestAddAPLExecuteCommandsDirectiveRequiresToken(t *testing.T) {
+	resp := NewEchoResponse()
+	if err := resp.AddAPLExecuteCommandsDirective("", SpeakItemCommand("headline")); !errors.Is(err, ErrMissingAPLToken) {
+		t.Errorf("AddAPLExecuteCommandsDirective() error = %v, want %v", err, ErrMissingAPLToken)
+	}
+	if len(resp.Response.Directives) != 0 {
+		t.Errorf("directives = %v, want none", resp.Response.Directives)
+	}
+}
+
+func TestGetAPLVisualContext(t *testing.T) {
+	req := parseTestRequest(t, "IntentRequest", `"context":{"Alexa.Presentation.APL":{
+		"token": "doc",
+		"version": "AriaRuntime-1.6",
+		"componentsVisibleOnScreen": [{"id": "headline", "type": "text"}]
+	}}`)
+
+	raw, ok := req.GetAPLVisualContext()
+	if !ok {
+		t.Fatal("GetAPLVisualContext() = false, want true")
+	}
+	var visual struct {
+		Token      string `json:"token"`
+		Components []struct {
+			ID string `json:"id"`
+		} `json:"componentsVisibleOnScreen"`
+	}
+	if err := json.Unmarshal(raw, &visual); err != nil {
+		t.Fatalf("could not decode visual context %s: %v", raw, err)
+	}
+	if visual.Token != "doc" || len(visual.Components) != 1 || visual.Components[0].ID != "headline" {
+		t.Errorf("GetAPLVisualContext() = %s, want the displayed document", raw)
+	}
+
+	if _, ok := parseTestRequest(t, "IntentRequest", "").GetAPLVisualContext(); ok {
+		t.Error("GetAPLVisualContext() without a displayed document = true, want false")
+	}
+}
@@ -0,0 +1,218 @@
+package skillserver
+
+import (
+	"strings"
+)
+
+/**
+ * Details about the AudioPlayer interface can be found on this page:
+ * https://developer.amazon.com/docs/custom-skills/audioplayer-interface-reference.html
+ */
+
+// AudioPlayerPlay is the directive type used to start streaming audio.
+const AudioPlayerPlay = "AudioPlayer.Play"
+
+// PlayBehavior describes how a Play directive affects the current playback and queue.
+type PlayBehavior string
+
+const (
+	// PlayReplaceAll immediately starts the new stream and replaces the queue.
+	PlayReplaceAll PlayBehavior = "REPLACE_ALL"
+	// PlayEnqueue adds the stream to the end of the queue.
+	PlayEnqueue PlayBehavior = "ENQUEUE"
+	// PlayReplaceEnqueued replaces all queued streams but keeps the current one playing.
+	PlayReplaceEnqueued PlayBehavior = "REPLACE_ENQUEUED"
+)
+
+// AudioPlayerDirective controls the playback of audio streams on the device.
+type AudioPlayerDirective struct {
+	Type         string       `json:"type"`
+	PlayBehavior PlayBehavior `json:"playBehavior,omitempty"`
+	AudioItem    *AudioItem   `json:"audioItem,omitempty"`
+}
+
+// DirectiveType returns the type of the audio player directive.
+func (d *AudioPlayerDirective) DirectiveType() string {
+	return d.Type
+}
+
+// AudioItem contains the stream to play and the optional metadata shown on devices with a screen.
+type AudioItem struct {
+	Stream   AudioStream        `json:"stream"`
+	Metadata *AudioItemMetadata `json:"metadata,omitempty"`
+}
+
+// AudioStream identifies the audio to play. The token is sent back in AudioPlayer requests to
+// identify the stream, ExpectedPreviousToken is required when enqueueing.
+type AudioStream struct {
+	URL                   string `json:"url"`
+	Token                 string `json:"token"`
+	ExpectedPreviousToken string `json:"expectedPreviousToken,omitempty"`
+	OffsetInMilliseconds  int    `json:"offsetInMilliseconds"`
+}
+
+// AudioItemMetadata is shown on the "now playing" screen of devices with a screen.
+type AudioItemMetadata struct {
+	Title           string        `json:"title,omitempty"`
+	Subtitle        string        `json:"subtitle,omitempty"`
+	Art             *DisplayImage `json:"art,omitempty"`
+	BackgroundImage *DisplayImage `json:"backgroundImage,omitempty"`
+}
+
+// DisplayImage is an image available in one or more sizes.
+type DisplayImage struct {
+	ContentDescription string        `json:"contentDescription,omitempty"`
+	Sources            []ImageSource `json:"sources"`
+}
+
+// ImageSource is a single size of a DisplayImage. The URL must use https.
+type ImageSource struct {
+	URL          string `json:"url"`
+	Size         string `json:"size,omitempty"`
+	WidthPixels  int    `json:"widthPixels,omitempty"`
+	HeightPixels int    `json:"heightPixels,omitempty"`
+}
+
+// AddAudioPlayerPlayDirective will add a directive to the response that plays the provided stream.
+func (r *EchoResponse) AddAudioPlayerPlayDirective(behavior PlayBehavior, stream AudioStream) *EchoResponse {
+	r.Response.Directives = append(r.Response.Directives, &AudioPlayerDirective{
+		Type:         AudioPlayerPlay,
+		PlayBehavior: behavior,
+		AudioItem:    &AudioItem{Stream: stream},
+	})
+
+	return r
+}
+
+// AddAudioPlayerPlayDirectiveWithMetadata is similar to `AddAudioPlayerPlayDirective` but includes metadata
+// describing the stream. An error is returned and no directive is added if any image source does not use https.
+func (r *EchoResponse) AddAudioPlayerPlayDirectiveWithMetadata(behavior PlayBehavior, stream AudioStream, metadata AudioItemMetadata) error {
+	for _, image := range []*DisplayImage{metadata.Art, metadata.BackgroundImage} {
+		if image == nil {
+			continue
+		}
+
+		for _, source := range image.Sources {
+			if err := requireHTTPS(source.URL); err != nil {
+				return err
+			}
+		}
+	}
+
+	r.Response.Directives = append(r.Response.Directives, &AudioPlayerDirective{
+		Type:         AudioPlayerPlay,
+		PlayBehavior: behavior,
+		AudioItem:    &AudioItem{Stream: stream, Metadata: &metadata},
+	})
+
+	return nil
+}
+
+// Strip the fields Amazon rejects in responses to `AudioPlayer.*` requests: speech, cards, reprompts and
+// the session flag are never allowed, `AudioPlayer.PlaybackStopped` allows no directives at all and
+// the other events only allow AudioPlayer directives. The names of the dropped fields are returned,
+// the session flag is not included as it's always set by `NewEchoResponse`.
+func (r *EchoResponse) normalizeAudioPlayerResponse(requestType string) []string {
+	var dropped []string
+	if r.Response.OutputSpeech != nil {
+		dropped = append(dropped, "outputSpeech")
+		r.Response.OutputSpeech = nil
+	}
+	if r.Response.Card != nil {
+		dropped = append(dropped, "card")
+		r.Response.Card = nil
+	}
+	if r.Response.Reprompt != nil {
+		dropped = append(dropped, "reprompt")
+		r.Response.Reprompt = nil
+	}
+	r.Response.ShouldEndSession = nil
+
+	var directives []Directive
+	for _, d := range r.Response.Directives {
+		if requestType != "AudioPlayer.PlaybackStopped" && strings.HasPrefix(d.DirectiveType(), "AudioPlayer.") {
+			directives = append(directives, d)
+		} else {
+			dropped = append(dropped, d.DirectiveType())
+		}
+	}
+	r.Response.Directives = directives
+
+	return dropped
+}
+
+// QueueManager plays a playlist gaplessly. Register `HandleAudioPlayerState` as the `OnAudioPlayerState`
+// handler of the application: it enqueues the next stream when the current one is nearly finished and skips
+// streams that fail to play. The tokens of the streams must be unique, as they identify the position in the
+// playlist. The stream offsets are ignored, every stream is played from the start.
+type QueueManager struct {
+	Playlist []AudioStream
+	Loop     bool // Continue with the first stream after the last one.
+}
+
+// Play will add a directive to the response that replaces the queue and starts the stream with the
+// given token. The response must answer a request that allows AudioPlayer directives, e.g. an intent
+// request. False is returned and no directive is added if no stream has the token.
+func (q *QueueManager) Play(r *EchoResponse, token string) bool {
+	index := q.indexOf(token)
+	if index < 0 {
+		return false
+	}
+
+	r.AddAudioPlayerPlayDirective(PlayReplaceAll, q.stream(index, ""))
+
+	return true
+}
+
+// HandleAudioPlayerState answers `AudioPlayer.PlaybackNearlyFinished` requests by enqueueing the stream
+// following the current one and `AudioPlayer.PlaybackFailed` requests by playing the stream following
+// the failed one. Nothing is added at the end of the playlist or for other requests.
+func (q *QueueManager) HandleAudioPlayerState(req *EchoRequest, resp *EchoResponse) {
+	token := req.Request.Token
+	next := q.next(token)
+	if next < 0 {
+		return
+	}
+
+	switch req.GetRequestType() {
+	case "AudioPlayer.PlaybackNearlyFinished":
+		resp.AddAudioPlayerPlayDirective(PlayEnqueue, q.stream(next, token))
+	case "AudioPlayer.PlaybackFailed":
+		resp.AddAudioPlayerPlayDirective(PlayReplaceAll, q.stream(next, ""))
+	}
+}
+
+// Returns the index of the stream with the given token, or -1 if no stream has the token.
+func (q *QueueManager) indexOf(token string) int {
+	for i, stream := range q.Playlist {
+		if stream.Token == token {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Returns the index of the stream following the one with the given token, or -1 if there is none.
+func (q *QueueManager) next(token string) int {
+	index := q.indexOf(token)
+	switch {
+	case index < 0:
+		return -1
+	case index+1 < len(q.Playlist):
+		return index + 1
+	case q.Loop:
+		return 0
+	}
+
+	return -1
+}
+
+// Returns the stream at the index, played from the start and expected to follow the given token.
+func (q *QueueManager) stream(index int, previousToken string) AudioStream {
+	stream := q.Playlist[index]
+	stream.OffsetInMilliseconds = 0
+	stream.ExpectedPreviousToken = previousToken
+
+	return stream
+}
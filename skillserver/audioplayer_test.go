@@ -0,0 +1,112 @@
+package skillserver
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAddAudioPlayerPlayDirectiveWithMetadata(t *testing.T) {
+	resp := NewEchoResponse()
+	err := resp.AddAudioPlayerPlayDirectiveWithMetadata(PlayReplaceAll,
+		AudioStream{URL: "https://example.com/episode.mp3", Token: "episode-1", OffsetInMilliseconds: 1500},
+		AudioItemMetadata{
+			Title:    "Episode 1",
+			Subtitle: "The Podcast",
+			Art: &DisplayImage{
+				ContentDescription: "Cover",
+				Sources:            []ImageSource{{URL: "https://example.com/cover.png", Size: "LARGE", WidthPixels: 1200, HeightPixels: 800}},
+			},
+			BackgroundImage: &DisplayImage{Sources: []ImageSource{{URL: "https://example.com/background.png"}}},
+		})
+	if err != nil {
+		t.Fatalf("AddAudioPlayerPlayDirectiveWithMetadata() error = %v", err)
+	}
+
+	checkDirectivesJSON(t, resp, `[{
+		"type": "AudioPlayer.Play",
+		"playBehavior": "REPLACE_ALL",
+		"audioItem": {
+			"stream": {"url": "https://example.com/episode.mp3", "token": "episode-1", "offsetInMilliseconds": 1500},
+			"metadata": {
+				"title": "Episode 1",
+				"subtitle": "The Podcast",
+				"art": {
+					"contentDescription": "Cover",
+					"sources": [{"url": "https://example.com/cover.png", "size": "LARGE", "widthPixels": 1200, "heightPixels": 800}]
+				},
+				"backgroundImage": {"sources": [{"url": "https://example.com/background.png"}]}
+			}
+		}
+	}]`)
+}
+
+func TestAddAudioPlayerPlayDirectiveWithMetadataRequiresHTTPS(t *testing.T) {
+	resp := NewEchoResponse()
+	err := resp.AddAudioPlayerPlayDirectiveWithMetadata(PlayReplaceAll,
+		AudioStream{URL: "https://example.com/episode.mp3", Token: "episode-1"},
+		AudioItemMetadata{BackgroundImage: &DisplayImage{Sources: []ImageSource{{URL: "http://example.com/background.png"}}}})
+	if !errors.Is(err, ErrInsecureURL) {
+		t.Errorf("AddAudioPlayerPlayDirectiveWithMetadata() error = %v, want %v", err, ErrInsecureURL)
+	}
+	if len(resp.Response.Directives) != 0 {
+		t.Errorf("directives = %v, want none", resp.Response.Directives)
+	}
+}
+
+func TestQueueManager(t *testing.T) {
+	queue := &QueueManager{Playlist: []AudioStream{
+		{URL: "https://example.com/1.mp3", Token: "track-1"},
+		{URL: "https://example.com/2.mp3", Token: "track-2", OffsetInMilliseconds: 500},
+		{URL: "https://example.com/3.mp3", Token: "track-3"},
+	}}
+
+	resp := NewEchoResponse()
+	if !queue.Play(resp, "track-1") {
+		t.Fatal("Play(track-1) = false, want true")
+	}
+	checkDirectivesJSON(t, resp, `[{"type": "AudioPlayer.Play", "playBehavior": "REPLACE_ALL",
+		"audioItem": {"stream": {"url": "https://example.com/1.mp3", "token": "track-1", "offsetInMilliseconds": 0}}}]`)
+
+	events := []struct {
+		requestType string
+		token       string
+		want        string
+	}{
+		{"AudioPlayer.PlaybackStarted", "track-1", `null`},
+		{"AudioPlayer.PlaybackNearlyFinished", "track-1", `[{"type": "AudioPlayer.Play", "playBehavior": "ENQUEUE",
+			"audioItem": {"stream": {"url": "https://example.com/2.mp3", "token": "track-2", "expectedPreviousToken": "track-1", "offsetInMilliseconds": 0}}}]`},
+		{"AudioPlayer.PlaybackFailed", "track-2", `[{"type": "AudioPlayer.Play", "playBehavior": "REPLACE_ALL",
+			"audioItem": {"stream": {"url": "https://example.com/3.mp3", "token": "track-3", "offsetInMilliseconds": 0}}}]`},
+		{"AudioPlayer.PlaybackNearlyFinished", "track-3", `null`},
+		{"AudioPlayer.PlaybackNearlyFinished", "unknown", `null`},
+	}
+	for _, event := range events {
+		req := &EchoRequest{}
+		req.Request.Type = event.requestType
+		req.Request.Token = event.token
+
+		resp := NewEchoResponse()
+		queue.HandleAudioPlayerState(req, resp)
+		checkDirectivesJSON(t, resp, event.want)
+	}
+
+	if queue.Play(NewEchoResponse(), "unknown") {
+		t.Error("Play(unknown) = true, want false")
+	}
+}
+
+func TestQueueManagerLoops(t *testing.T) {
+	queue := &QueueManager{Loop: true, Playlist: []AudioStream{
+		{URL: "https://example.com/1.mp3", Token: "track-1"},
+		{URL: "https://example.com/2.mp3", Token: "track-2"},
+	}}
+
+	req := &EchoRequest{}
+	req.Request.Type = "AudioPlayer.PlaybackNearlyFinished"
+	req.Request.Token = "track-2"
+
+	resp := NewEchoResponse()
+	queue.HandleAudioPlayerState(req, resp)
+	checkDirectivesJSON(t, resp, `[{"type": "AudioPlayer.Play", "playBehavior": "ENQUEUE",
+		"audioItem": {"stream": {"url": "https://example.com/1.mp3", "token": "track-1", "expectedPreviousToken": "track-2", "offsetInMilliseconds": 0}}}]`)
+}
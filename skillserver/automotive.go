@@ -0,0 +1,18 @@
+package skillserver
+
+/**
+ * Details about skills for Alexa Auto can be found on this page:
+ * https://developer.amazon.com/docs/alexa/custom-skills/create-skills-for-alexa-enabled-vehicles.html
+ */
+
+// Automotive is sent in the context of requests from Alexa enabled vehicles. Skills should avoid
+// responses that rely on a screen or require the driver's attention.
+type Automotive struct {
+	SpecVersion string `json:"specVersion,omitempty"`
+}
+
+// GetAutomotive returns the Automotive context object. False is returned if the request doesn't come
+// from a vehicle.
+func (r *EchoRequest) GetAutomotive() (*Automotive, bool) {
+	return r.Context.Automotive, r.Context.Automotive != nil
+}
@@ -0,0 +1,18 @@
+package skillserver
+
+import (
+	"testing"
+)
+
+func TestGetAutomotive(t *testing.T) {
+	req := parseTestRequest(t, "LaunchRequest", `"context":{"Automotive":{"specVersion":"1.0"}}`)
+	automotive, ok := req.GetAutomotive()
+	if !ok || automotive.SpecVersion != "1.0" {
+		t.Errorf("GetAutomotive() = %+v, %v, want spec version 1.0", automotive, ok)
+	}
+
+	req = parseTestRequest(t, "LaunchRequest", `"context":{"System":{}}`)
+	if automotive, ok := req.GetAutomotive(); ok || automotive != nil {
+		t.Errorf("GetAutomotive() = %+v, %v, want no automotive context", automotive, ok)
+	}
+}
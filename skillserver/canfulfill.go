@@ -0,0 +1,81 @@
+package skillserver
+
+import (
+	"errors"
+	"fmt"
+)
+
+/**
+ * Details about the CanFulfillIntentRequest can be found on this page:
+ * https://developer.amazon.com/docs/custom-skills/implement-canfulfillintentrequest-for-name-free-interaction.html
+ */
+
+// Values accepted for the canFulfill and canUnderstand fields of a CanFulfillIntent response.
+const (
+	CanFulfillYes   = "YES"
+	CanFulfillNo    = "NO"
+	CanFulfillMaybe = "MAYBE"
+)
+
+// ErrInvalidCanFulfillValue is returned when a value other than YES, NO or MAYBE is used.
+var ErrInvalidCanFulfillValue = errors.New("value must be YES, NO or MAYBE")
+
+// EchoCanFulfillIntent tells the Alexa service whether the skill can understand and fulfill the intent
+// of a CanFulfillIntentRequest, overall and for every single slot.
+type EchoCanFulfillIntent struct {
+	CanFulfill string                        `json:"canFulfill"`
+	Slots      map[string]EchoCanFulfillSlot `json:"slots,omitempty"`
+}
+
+// EchoCanFulfillSlot contains whether the skill understands the value of a slot and can fulfill it.
+type EchoCanFulfillSlot struct {
+	CanUnderstand string `json:"canUnderstand"`
+	CanFulfill    string `json:"canFulfill"`
+}
+
+func validateCanFulfill(value string) error {
+	if value != CanFulfillYes && value != CanFulfillNo && value != CanFulfillMaybe {
+		return fmt.Errorf("%w: %q", ErrInvalidCanFulfillValue, value)
+	}
+
+	return nil
+}
+
+// SetCanFulfill sets whether the skill can fulfill the intent of a CanFulfillIntentRequest.
+func (r *EchoResponse) SetCanFulfill(canFulfill string) error {
+	if err := validateCanFulfill(canFulfill); err != nil {
+		return err
+	}
+
+	if r.Response.CanFulfillIntent == nil {
+		r.Response.CanFulfillIntent = &EchoCanFulfillIntent{}
+	}
+	r.Response.CanFulfillIntent.CanFulfill = canFulfill
+
+	return nil
+}
+
+// SetSlotCanFulfill sets whether the skill understands the value of the given slot and can fulfill
+// the intent with it. The overall value should be set with `SetCanFulfill` as well.
+func (r *EchoResponse) SetSlotCanFulfill(slot, canUnderstand, canFulfill string) error {
+	if err := validateCanFulfill(canUnderstand); err != nil {
+		return err
+	}
+
+	if err := validateCanFulfill(canFulfill); err != nil {
+		return err
+	}
+
+	if r.Response.CanFulfillIntent == nil {
+		r.Response.CanFulfillIntent = &EchoCanFulfillIntent{}
+	}
+	if r.Response.CanFulfillIntent.Slots == nil {
+		r.Response.CanFulfillIntent.Slots = make(map[string]EchoCanFulfillSlot)
+	}
+	r.Response.CanFulfillIntent.Slots[slot] = EchoCanFulfillSlot{
+		CanUnderstand: canUnderstand,
+		CanFulfill:    canFulfill,
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package skillserver
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSetSlotCanFulfill(t *testing.T) {
+	resp := NewEchoResponse()
+	if err := resp.SetCanFulfill(CanFulfillMaybe); err != nil {
+		t.Fatalf("SetCanFulfill() error = %v", err)
+	}
+	if err := resp.SetSlotCanFulfill("city", CanFulfillYes, CanFulfillYes); err != nil {
+		t.Fatalf("SetSlotCanFulfill(city) error = %v", err)
+	}
+	if err := resp.SetSlotCanFulfill("date", CanFulfillYes, CanFulfillNo); err != nil {
+		t.Fatalf("SetSlotCanFulfill(date) error = %v", err)
+	}
+
+	raw := serializedResponseFields(t, resp)["canFulfillIntent"]
+	var got, want interface{}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("could not decode canFulfillIntent %s: %v", raw, err)
+	}
+	json.Unmarshal([]byte(`{
+		"canFulfill": "MAYBE",
+		"slots": {
+			"city": {"canUnderstand": "YES", "canFulfill": "YES"},
+			"date": {"canUnderstand": "YES", "canFulfill": "NO"}
+		}
+	}`), &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("canFulfillIntent = %s, want the overall and per-slot values", raw)
+	}
+}
+
+func TestSetSlotCanFulfillRejectsInvalidValues(t *testing.T) {
+	resp := NewEchoResponse()
+	if err := resp.SetCanFulfill("yes"); !errors.Is(err, ErrInvalidCanFulfillValue) {
+		t.Errorf("SetCanFulfill() error = %v, want %v", err, ErrInvalidCanFulfillValue)
+	}
+	if err := resp.SetSlotCanFulfill("city", "PERHAPS", CanFulfillYes); !errors.Is(err, ErrInvalidCanFulfillValue) {
+		t.Errorf("SetSlotCanFulfill() error = %v, want %v", err, ErrInvalidCanFulfillValue)
+	}
+	if err := resp.SetSlotCanFulfill("city", CanFulfillYes, ""); !errors.Is(err, ErrInvalidCanFulfillValue) {
+		t.Errorf("SetSlotCanFulfill() error = %v, want %v", err, ErrInvalidCanFulfillValue)
+	}
+
+	if resp.Response.CanFulfillIntent != nil {
+		t.Errorf("canFulfillIntent = %+v, want none", resp.Response.CanFulfillIntent)
+	}
+}
@@ -0,0 +1,102 @@
+package skillserver
+
+import (
+	"encoding/json"
+)
+
+/**
+ * Details about skill connections can be found on this page:
+ * https://developer.amazon.com/docs/custom-skills/skill-connections.html
+ */
+
+// ConnectionsSendRequest is the directive type used to hand a task to Alexa or another skill.
+const ConnectionsSendRequest = "Connections.SendRequest"
+
+// ConnectionsDirective sends a request to a connection. The token is sent back unchanged in the
+// `Connections.Response` request, so the skill can match the response to its request.
+type ConnectionsDirective struct {
+	Type    string          `json:"type"`
+	Name    string          `json:"name"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	Token   string          `json:"token"`
+}
+
+// DirectiveType returns the type of the connections directive.
+func (d *ConnectionsDirective) DirectiveType() string {
+	return d.Type
+}
+
+// EchoConnectionsStatus is the status of a `Connections.Response` request, the code follows HTTP status codes.
+type EchoConnectionsStatus struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// AddConnectionsSendRequestDirective will add a directive to the response that sends the payload to the
+// connection with the given name, e.g. "Buy" or "AskFor". The caller supplied token can be read from the
+// resulting `Connections.Response` request with `EchoRequest.GetConnectionsToken`.
+func (r *EchoResponse) AddConnectionsSendRequestDirective(name string, payload json.RawMessage, token string) *EchoResponse {
+	r.Response.Directives = append(r.Response.Directives, &ConnectionsDirective{
+		Type:    ConnectionsSendRequest,
+		Name:    name,
+		Payload: payload,
+		Token:   token,
+	})
+
+	return r
+}
+
+// GetConnectionsToken returns the token of the `Connections.SendRequest` directive a `Connections.Response`
+// request answers.
+func (r *EchoRequest) GetConnectionsToken() string {
+	return r.Request.Token
+}
+
+// Statuses of the `Connections.Response` to an AskFor permissions consent request.
+const (
+	ConsentAccepted    = "ACCEPTED"
+	ConsentDenied      = "DENIED"
+	ConsentNotAnswered = "NOT_ANSWERED"
+)
+
+// AddAskForPermissionsConsentDirective will add a directive to the response that asks the end user by voice to
+// grant the skill the given permission scopes, e.g. "alexa::alerts:reminders:skill:readwrite". The answer is sent
+// as a `Connections.Response` request, see `GetAskForPermissionsConsentStatus`. Unlike `AskForPermissionsConsentCard`
+// the end user doesn't have to open the Alexa app.
+func (r *EchoResponse) AddAskForPermissionsConsentDirective(permissions []string, token string) *EchoResponse {
+	type permissionScope struct {
+		PermissionScope string `json:"permissionScope"`
+		ConsentLevel    string `json:"consentLevel"`
+	}
+
+	scopes := make([]permissionScope, 0, len(permissions))
+	for _, permission := range permissions {
+		scopes = append(scopes, permissionScope{PermissionScope: permission, ConsentLevel: "ACCOUNT"})
+	}
+
+	payload, _ := json.Marshal(struct {
+		Type             string            `json:"@type"`
+		Version          string            `json:"@version"`
+		PermissionScopes []permissionScope `json:"permissionScopes"`
+	}{"AskForPermissionsConsentRequest", "2", scopes})
+
+	return r.AddConnectionsSendRequestDirective("AskFor", payload, token)
+}
+
+// GetAskForPermissionsConsentStatus returns the status of the answer to an AskFor permissions consent request,
+// one of ConsentAccepted, ConsentDenied or ConsentNotAnswered. False is returned if the request is not
+// the `Connections.Response` to such a request.
+func (r *EchoRequest) GetAskForPermissionsConsentStatus() (string, bool) {
+	if r.GetRequestType() != "Connections.Response" || r.Request.Name != "AskFor" {
+		return "", false
+	}
+
+	var payload struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(r.Request.Payload, &payload); err != nil || payload.Status == "" {
+		return "", false
+	}
+
+	return payload.Status, true
+}
@@ -0,0 +1,98 @@
+package skillserver
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestConnectionsTokenRoundTrip(t *testing.T) {
+	resp := NewEchoResponse().AddConnectionsSendRequestDirective("Buy", json.RawMessage(`{"InSkillProduct":{"productId":"p"}}`), "order-42")
+	checkDirectivesJSON(t, resp, `[{
+		"type": "Connections.SendRequest",
+		"name": "Buy",
+		"payload": {"InSkillProduct": {"productId": "p"}},
+		"token": "order-42"
+	}]`)
+
+	// The Alexa service answers with the token of the directive.
+	token := resp.Response.Directives[0].(*ConnectionsDirective).Token
+	req, err := ParseEchoRequest(strings.NewReader(`{"request":{
+		"type": "Connections.Response",
+		"name": "Buy",
+		"status": {"code": "200", "message": "OK"},
+		"payload": {"purchaseResult": "ACCEPTED"},
+		"token": "` + token + `"
+	}}`))
+	if err != nil {
+		t.Fatalf("ParseEchoRequest() error = %v", err)
+	}
+
+	if got := req.GetConnectionsToken(); got != "order-42" {
+		t.Errorf("GetConnectionsToken() = %q, want %q", got, "order-42")
+	}
+	if status := req.Request.Status; status == nil || status.Code != "200" || status.Message != "OK" {
+		t.Errorf("Status = %+v, want 200 OK", status)
+	}
+	if got := string(req.Request.Payload); got != `{"purchaseResult": "ACCEPTED"}` {
+		t.Errorf("Payload = %s, want the raw payload", got)
+	}
+}
+
+func TestShouldLinkResultBeReturned(t *testing.T) {
+	req := parseTestRequest(t, "LaunchRequest", "")
+	if req.Request.ShouldLinkResultBeReturned {
+		t.Error("ShouldLinkResultBeReturned = true, want false")
+	}
+
+	req, err := ParseEchoRequest(strings.NewReader(`{"request":{"type":"LaunchRequest","shouldLinkResultBeReturned":true}}`))
+	if err != nil {
+		t.Fatalf("ParseEchoRequest() error = %v", err)
+	}
+	if !req.Request.ShouldLinkResultBeReturned {
+		t.Error("ShouldLinkResultBeReturned = false, want true")
+	}
+}
+
+func TestAddAskForPermissionsConsentDirective(t *testing.T) {
+	resp := NewEchoResponse().AddAskForPermissionsConsentDirective([]string{"alexa::alerts:reminders:skill:readwrite"}, "reminder")
+
+	checkDirectivesJSON(t, resp, `[{
+		"type": "Connections.SendRequest",
+		"name": "AskFor",
+		"payload": {
+			"@type": "AskForPermissionsConsentRequest",
+			"@version": "2",
+			"permissionScopes": [{"permissionScope": "alexa::alerts:reminders:skill:readwrite", "consentLevel": "ACCOUNT"}]
+		},
+		"token": "reminder"
+	}]`)
+}
+
+func TestGetAskForPermissionsConsentStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    string
+		wantStatus string
+		wantOK     bool
+	}{
+		{"accepted", `"name": "AskFor", "payload": {"permissionScope": "alexa::alerts:reminders:skill:readwrite", "status": "ACCEPTED"}`, ConsentAccepted, true},
+		{"denied", `"name": "AskFor", "payload": {"status": "DENIED"}`, ConsentDenied, true},
+		{"other connection", `"name": "Buy", "payload": {"purchaseResult": "ACCEPTED"}`, "", false},
+		{"no status", `"name": "AskFor", "payload": {}`, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := ParseEchoRequest(strings.NewReader(`{"request":{"type": "Connections.Response", ` + tt.request + `}}`))
+			if err != nil {
+				t.Fatalf("ParseEchoRequest() error = %v", err)
+			}
+
+			status, ok := req.GetAskForPermissionsConsentStatus()
+			if status != tt.wantStatus || ok != tt.wantOK {
+				t.Errorf("GetAskForPermissionsConsentStatus() = %q, %v, want %q, %v", status, ok, tt.wantStatus, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package skillserver
+
+/**
+ * The Alexa.DataStore.PackageManager interface manages the packages of gadget and widget skills, e.g. APL widgets,
+ * installed on a device.
+ */
+
+// Directive types of the Alexa.DataStore.PackageManager interface.
+const (
+	PackageManagerInstallPackage = "Alexa.DataStore.PackageManager.InstallPackage"
+	PackageManagerRemovePackage  = "Alexa.DataStore.PackageManager.RemovePackage"
+)
+
+// PackageManagerDirective installs or removes a package, e.g. a widget, on the device. The result is reported
+// with an `Alexa.DataStore.PackageManager.*` request, see `EchoApplication.OnPackageManagerRequest`.
+type PackageManagerDirective struct {
+	Type      string `json:"type"`
+	PackageID string `json:"packageId"`
+}
+
+// DirectiveType returns the type of the package manager directive.
+func (d *PackageManagerDirective) DirectiveType() string {
+	return d.Type
+}
+
+// AddInstallPackageDirective will add a directive to the response that installs the package with the given ID.
+func (r *EchoResponse) AddInstallPackageDirective(packageID string) *EchoResponse {
+	return r.AddDirective(&PackageManagerDirective{Type: PackageManagerInstallPackage, PackageID: packageID})
+}
+
+// AddRemovePackageDirective will add a directive to the response that removes the package with the given ID.
+func (r *EchoResponse) AddRemovePackageDirective(packageID string) *EchoResponse {
+	return r.AddDirective(&PackageManagerDirective{Type: PackageManagerRemovePackage, PackageID: packageID})
+}
@@ -0,0 +1,35 @@
+package skillserver
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPackageManagerDirectives(t *testing.T) {
+	resp := NewEchoResponse().AddInstallPackageDirective("widget-1").AddRemovePackageDirective("widget-0")
+
+	checkDirectivesJSON(t, resp, `[
+		{"type": "Alexa.DataStore.PackageManager.InstallPackage", "packageId": "widget-1"},
+		{"type": "Alexa.DataStore.PackageManager.RemovePackage", "packageId": "widget-0"}
+	]`)
+}
+
+func TestOnPackageManagerRequest(t *testing.T) {
+	var gotType string
+	var gotPayload string
+	h := testAppHandler(t, EchoApplication{
+		OnPackageManagerRequest: func(req *EchoRequest, resp *EchoResponse) {
+			gotType = req.GetRequestType()
+			gotPayload = string(req.Request.Payload)
+		},
+	})
+
+	w := postTestEcho(h, testRequestBodyWith("Alexa.DataStore.PackageManager.InstallationError",
+		`"payload": {"packageId": "widget-1"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if gotType != "Alexa.DataStore.PackageManager.InstallationError" || gotPayload != `{"packageId": "widget-1"}` {
+		t.Errorf("OnPackageManagerRequest called with %s %s, want the installation error", gotType, gotPayload)
+	}
+}
@@ -0,0 +1,130 @@
+package skillserver
+
+import (
+	"encoding/json"
+)
+
+// DeviceCapabilities summarizes the interfaces supported by the device a request was sent from.
+type DeviceCapabilities struct {
+	HasScreen      bool   // The device supports APL or the Display interface.
+	HasAudioPlayer bool   // The device supports streaming audio with the AudioPlayer interface.
+	HasVideoApp    bool   // The device supports playing videos with the VideoApp interface.
+	APLVersion     string // The maximum APL version supported by the device, empty without APL support.
+}
+
+// SupportsInterface returns whether the device supports the interface with the given name,
+// e.g. "AudioPlayer" or "Alexa.Presentation.APL".
+func (r *EchoRequest) SupportsInterface(name string) bool {
+	_, ok := r.Context.System.Device.SupportedInterfaces[name]
+	return ok
+}
+
+// GetAvailableExtensions returns the raw JSON of the extensions available on the device, keyed by extension
+// URI, e.g. "aplext:backstack:10", as listed in the "Extensions" context object. It returns nil if the
+// device doesn't report any extensions.
+func (r *EchoRequest) GetAvailableExtensions() map[string]json.RawMessage {
+	raw, ok := r.GetContextObject("Extensions")
+	if !ok {
+		return nil
+	}
+
+	var extensions struct {
+		Available map[string]json.RawMessage `json:"available"`
+	}
+	if err := json.Unmarshal(raw, &extensions); err != nil {
+		return nil
+	}
+
+	return extensions.Available
+}
+
+// DeviceCapabilities returns the capabilities of the device derived from its supported interfaces.
+func (r *EchoRequest) DeviceCapabilities() DeviceCapabilities {
+	capabilities := DeviceCapabilities{
+		HasScreen:      r.SupportsInterface("Alexa.Presentation.APL") || r.SupportsInterface("Display"),
+		HasAudioPlayer: r.SupportsInterface("AudioPlayer"),
+		HasVideoApp:    r.SupportsInterface("VideoApp"),
+	}
+
+	if apl, ok := r.Context.System.Device.SupportedInterfaces["Alexa.Presentation.APL"]; ok {
+		var details struct {
+			Runtime struct {
+				MaxVersion string `json:"maxVersion"`
+			} `json:"runtime"`
+		}
+		if err := json.Unmarshal(apl, &details); err == nil {
+			capabilities.APLVersion = details.Runtime.MaxVersion
+		}
+	}
+
+	return capabilities
+}
+
+// Viewport profiles returned by `EchoRequest.ViewportProfile`.
+const (
+	ViewportProfileHubRoundSmall      = "HUB_ROUND_SMALL"
+	ViewportProfileHubLandscapeSmall  = "HUB_LANDSCAPE_SMALL"
+	ViewportProfileHubLandscapeMedium = "HUB_LANDSCAPE_MEDIUM"
+	ViewportProfileHubLandscapeLarge  = "HUB_LANDSCAPE_LARGE"
+	ViewportProfileHubLandscapeXLarge = "HUB_LANDSCAPE_XLARGE"
+	ViewportProfileHubPortraitMedium  = "HUB_PORTRAIT_MEDIUM"
+	ViewportProfileTVFullscreen       = "TV_FULLSCREEN"
+	ViewportProfileUnknown            = "UNKNOWN_VIEWPORT_PROFILE"
+)
+
+// ViewportProfile returns a coarse category of the screen of the device, e.g. HUB_LANDSCAPE_MEDIUM for an Echo Show 8,
+// so the skill can pick an APL document per profile. The category is derived from the mode, shape and size in
+// density-independent pixels of the "Viewport" context object. ViewportProfileUnknown is returned for devices
+// without a screen or with a screen that fits no category.
+func (r *EchoRequest) ViewportProfile() string {
+	raw, ok := r.GetContextObject("Viewport")
+	if !ok {
+		return ViewportProfileUnknown
+	}
+
+	var viewport struct {
+		Mode        string  `json:"mode"`
+		Shape       string  `json:"shape"`
+		PixelWidth  float64 `json:"pixelWidth"`
+		PixelHeight float64 `json:"pixelHeight"`
+		DPI         float64 `json:"dpi"`
+	}
+	if err := json.Unmarshal(raw, &viewport); err != nil || viewport.PixelWidth <= 0 || viewport.PixelHeight <= 0 {
+		return ViewportProfileUnknown
+	}
+
+	if viewport.DPI <= 0 {
+		viewport.DPI = 160
+	}
+	width := viewport.PixelWidth * 160 / viewport.DPI
+	height := viewport.PixelHeight * 160 / viewport.DPI
+
+	switch viewport.Mode {
+	case "TV":
+		if width >= height {
+			return ViewportProfileTVFullscreen
+		}
+	case "HUB":
+		switch {
+		case viewport.Shape == "ROUND":
+			if width < 600 && height < 600 {
+				return ViewportProfileHubRoundSmall
+			}
+		case width > height:
+			switch {
+			case width < 1280 && height < 600:
+				return ViewportProfileHubLandscapeSmall
+			case width < 1280 && height < 960:
+				return ViewportProfileHubLandscapeMedium
+			case width < 1920 && height < 960:
+				return ViewportProfileHubLandscapeLarge
+			case height < 1280:
+				return ViewportProfileHubLandscapeXLarge
+			}
+		case width < 960 && height < 1280:
+			return ViewportProfileHubPortraitMedium
+		}
+	}
+
+	return ViewportProfileUnknown
+}
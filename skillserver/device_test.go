@@ -0,0 +1,77 @@
+package skillserver
+
+import (
+	"testing"
+)
+
+func TestDeviceCapabilities(t *testing.T) {
+	tests := []struct {
+		name       string
+		interfaces string
+		want       DeviceCapabilities
+	}{
+		{"Echo Show", `{
+			"AudioPlayer": {},
+			"VideoApp": {},
+			"Alexa.Presentation.APL": {"runtime": {"maxVersion": "1.6"}}
+		}`, DeviceCapabilities{HasScreen: true, HasAudioPlayer: true, HasVideoApp: true, APLVersion: "1.6"}},
+		{"Echo Dot", `{"AudioPlayer": {}}`, DeviceCapabilities{HasAudioPlayer: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := parseTestRequest(t, "LaunchRequest", `"context":{"System":{"device":{"supportedInterfaces":`+tt.interfaces+`}}}`)
+			if got := req.DeviceCapabilities(); got != tt.want {
+				t.Errorf("DeviceCapabilities() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestViewportProfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		viewport string
+		want     string
+	}{
+		{"Echo Spot", `{"mode": "HUB", "shape": "ROUND", "pixelWidth": 480, "pixelHeight": 480, "dpi": 160}`, ViewportProfileHubRoundSmall},
+		{"Echo Show 5", `{"mode": "HUB", "shape": "RECTANGLE", "pixelWidth": 960, "pixelHeight": 480, "dpi": 160}`, ViewportProfileHubLandscapeSmall},
+		{"Echo Show 8", `{"mode": "HUB", "shape": "RECTANGLE", "pixelWidth": 1280, "pixelHeight": 800, "dpi": 213}`, ViewportProfileHubLandscapeMedium},
+		{"Echo Show", `{"mode": "HUB", "shape": "RECTANGLE", "pixelWidth": 1280, "pixelHeight": 800, "dpi": 160}`, ViewportProfileHubLandscapeLarge},
+		{"portrait hub", `{"mode": "HUB", "shape": "RECTANGLE", "pixelWidth": 600, "pixelHeight": 1024, "dpi": 160}`, ViewportProfileHubPortraitMedium},
+		{"Fire TV", `{"mode": "TV", "shape": "RECTANGLE", "pixelWidth": 1920, "pixelHeight": 1080, "dpi": 320}`, ViewportProfileTVFullscreen},
+		{"no size", `{"mode": "HUB", "shape": "RECTANGLE"}`, ViewportProfileUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := parseTestRequest(t, "LaunchRequest", `"context":{"Viewport":`+tt.viewport+`}`)
+			if got := req.ViewportProfile(); got != tt.want {
+				t.Errorf("ViewportProfile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := parseTestRequest(t, "LaunchRequest", "").ViewportProfile(); got != ViewportProfileUnknown {
+		t.Errorf("ViewportProfile() without a viewport = %q, want %q", got, ViewportProfileUnknown)
+	}
+}
+
+func TestGetAvailableExtensions(t *testing.T) {
+	req := parseTestRequest(t, "LaunchRequest", `"context":{"Extensions":{"available":{
+		"aplext:backstack:10": {},
+		"alexaext:smartmotion:10": {"version": "1.0"}
+	}}}`)
+
+	extensions := req.GetAvailableExtensions()
+	if len(extensions) != 2 || string(extensions["alexaext:smartmotion:10"]) != `{"version": "1.0"}` {
+		t.Errorf("GetAvailableExtensions() = %s, want the two extensions", extensions)
+	}
+	if _, ok := extensions["aplext:backstack:10"]; !ok {
+		t.Error("GetAvailableExtensions() is missing aplext:backstack:10")
+	}
+
+	if extensions := parseTestRequest(t, "LaunchRequest", "").GetAvailableExtensions(); extensions != nil {
+		t.Errorf("GetAvailableExtensions() without extensions = %s, want nil", extensions)
+	}
+}
@@ -15,6 +15,9 @@ const (
 
 	// ConfirmIntent indicates to the Alexa service that the complete intent should be confimed by the user.
 	ConfirmIntent Type = "Dialog.ConfirmIntent"
+
+	// DelegateRequest hands the control of the dialog to Alexa Conversations or back to the skill.
+	DelegateRequest Type = "Dialog.DelegateRequest"
 )
 
 const (
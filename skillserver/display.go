@@ -0,0 +1,104 @@
+package skillserver
+
+import (
+	"fmt"
+	"strings"
+)
+
+/**
+ * Details about the Display interface can be found on this page:
+ * https://developer.amazon.com/docs/custom-skills/display-interface-reference.html
+ */
+
+// DisplayRenderTemplate is the directive type used to show a display template on a device with a screen.
+const DisplayRenderTemplate = "Display.RenderTemplate"
+
+// ListCardItem is a single item of a list shown with `StandardListCard`.
+type ListCardItem struct {
+	Token         string // Sent back when the end user selects the item, generated if empty.
+	PrimaryText   string
+	SecondaryText string
+	ImageURL      string // Must use https.
+}
+
+// DisplayDirective renders a display template on the device.
+type DisplayDirective struct {
+	Type     string          `json:"type"`
+	Template DisplayTemplate `json:"template"`
+}
+
+// DirectiveType returns the type of the display directive.
+func (d *DisplayDirective) DirectiveType() string {
+	return d.Type
+}
+
+// DisplayTemplate is a template of the Display interface, e.g. "ListTemplate1".
+type DisplayTemplate struct {
+	Type      string            `json:"type"`
+	Token     string            `json:"token,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	ListItems []DisplayListItem `json:"listItems,omitempty"`
+}
+
+// DisplayListItem is a single item of a list template.
+type DisplayListItem struct {
+	Token       string             `json:"token"`
+	Image       *DisplayImage      `json:"image,omitempty"`
+	TextContent DisplayTextContent `json:"textContent"`
+}
+
+// DisplayTextContent contains the texts shown for an item of a list template.
+type DisplayTextContent struct {
+	PrimaryText   *DisplayText `json:"primaryText,omitempty"`
+	SecondaryText *DisplayText `json:"secondaryText,omitempty"`
+}
+
+// DisplayText is a text shown by a display template.
+type DisplayText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// StandardListCard will show the items as a list on the screen of the device with a `Display.RenderTemplate`
+// directive and as a numbered list in a simple card in the Alexa app. Devices without a screen reject the directive,
+// so only use this if the request's device supports the "Display" interface, see `EchoRequest.SupportsInterface`.
+// Image URLs not using https are left out.
+func (r *EchoResponse) StandardListCard(title string, items []ListCardItem) *EchoResponse {
+	listItems := make([]DisplayListItem, 0, len(items))
+	lines := make([]string, 0, len(items))
+	for i, item := range items {
+		listItem := DisplayListItem{
+			Token: item.Token,
+			TextContent: DisplayTextContent{
+				PrimaryText: &DisplayText{Type: "PlainText", Text: item.PrimaryText},
+			},
+		}
+		if listItem.Token == "" {
+			listItem.Token = fmt.Sprintf("item_%d", i+1)
+		}
+		if item.SecondaryText != "" {
+			listItem.TextContent.SecondaryText = &DisplayText{Type: "PlainText", Text: item.SecondaryText}
+		}
+		if item.ImageURL != "" && requireHTTPS(item.ImageURL) == nil {
+			listItem.Image = &DisplayImage{Sources: []ImageSource{{URL: item.ImageURL}}}
+		}
+		listItems = append(listItems, listItem)
+
+		line := fmt.Sprintf("%d. %s", i+1, item.PrimaryText)
+		if item.SecondaryText != "" {
+			line += " - " + item.SecondaryText
+		}
+		lines = append(lines, line)
+	}
+
+	r.Response.Directives = append(r.Response.Directives, &DisplayDirective{
+		Type: DisplayRenderTemplate,
+		Template: DisplayTemplate{
+			Type:      "ListTemplate1",
+			Title:     title,
+			ListItems: listItems,
+		},
+	})
+
+	return r.SimpleCard(title, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,38 @@
+package skillserver
+
+import (
+	"testing"
+)
+
+func TestStandardListCard(t *testing.T) {
+	resp := NewEchoResponse().StandardListCard("Groceries", []ListCardItem{
+		{Token: "milk", PrimaryText: "Milk", SecondaryText: "2 liters", ImageURL: "https://example.com/milk.png"},
+		{PrimaryText: "Bread", ImageURL: "http://example.com/bread.png"},
+		{PrimaryText: "Eggs"},
+	})
+
+	checkDirectivesJSON(t, resp, `[{
+		"type": "Display.RenderTemplate",
+		"template": {
+			"type": "ListTemplate1",
+			"title": "Groceries",
+			"listItems": [
+				{
+					"token": "milk",
+					"image": {"sources": [{"url": "https://example.com/milk.png"}]},
+					"textContent": {
+						"primaryText": {"type": "PlainText", "text": "Milk"},
+						"secondaryText": {"type": "PlainText", "text": "2 liters"}
+					}
+				},
+				{"token": "item_2", "textContent": {"primaryText": {"type": "PlainText", "text": "Bread"}}},
+				{"token": "item_3", "textContent": {"primaryText": {"type": "PlainText", "text": "Eggs"}}}
+			]
+		}
+	}]`)
+
+	card := resp.Response.Card
+	if want := "1. Milk - 2 liters\n2. Bread\n3. Eggs"; card.Type != "Simple" || card.Title != "Groceries" || card.Content != want {
+		t.Errorf("card = %+v, want a simple card listing the items", card)
+	}
+}
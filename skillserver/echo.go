@@ -1,9 +1,18 @@
 package skillserver
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"reflect"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/mikeflynn/go-alexa/skillserver/dialog"
 )
@@ -22,13 +31,95 @@ const (
 	ConfNone ConfirmationStatus = "NONE"
 )
 
+// ResponseLimits contains the maximum sizes of a response accepted by the Alexa service.
+type ResponseLimits struct {
+	MaxSpeechLength int  // Characters of output speech or reprompt, including SSML tags.
+	MaxResponseSize int  // Bytes of the serialized response.
+	Reject          bool // Whether responses exceeding the limits should not be sent at all.
+}
+
+// DefaultResponseLimits are the limits documented in the Alexa Skills Kit response reference.
+var DefaultResponseLimits = ResponseLimits{
+	MaxSpeechLength: 8000,
+	MaxResponseSize: 24 * 1024,
+}
+
+var (
+	// ErrSpeechTooLong is returned when the output speech or reprompt exceeds the configured length.
+	ErrSpeechTooLong = errors.New("speech too long")
+
+	// ErrResponseTooLarge is returned when the serialized response exceeds the configured size.
+	ErrResponseTooLarge = errors.New("response too large")
+
+	// ErrInsecureURL is returned when a URL sent to the Alexa service does not use https.
+	ErrInsecureURL = errors.New("url must use https")
+
+	// ErrEmptyBody is returned by `ParseEchoRequest` when the body is empty.
+	ErrEmptyBody = errors.New("request body is empty")
+
+	// ErrInvalidJSON is returned by `ParseEchoRequest` when the body is not valid JSON or doesn't match
+	// the structure of an Alexa request.
+	ErrInvalidJSON = errors.New("request body is not valid json")
+
+	// ErrNotAlexaRequest is returned by `ParseEchoRequest` when the body is valid JSON but lacks the
+	// request type every Alexa request has, e.g. when a different client posts to the echo endpoint.
+	ErrNotAlexaRequest = errors.New("request body is not an alexa request")
+)
+
 // Request Functions
 
+// ParseEchoRequest decodes an EchoRequest from the reader, e.g. the body of an http.Request. It doesn't
+// check the signature, timestamp or application ID of the request, which the echo pipeline does before
+// decoding. Use it for custom pipelines, `StdApplication` handlers and tests. Bodies that can't be decoded
+// are reported with ErrEmptyBody, ErrInvalidJSON or ErrNotAlexaRequest, which can be checked with `errors.Is`.
+func ParseEchoRequest(r io.Reader) (*EchoRequest, error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, ErrEmptyBody
+	}
+
+	var echoReq *EchoRequest
+	if err := json.Unmarshal(body, &echoReq); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+	}
+
+	if echoReq == nil || echoReq.Request.Type == "" {
+		return nil, ErrNotAlexaRequest
+	}
+
+	return echoReq, nil
+}
+
+// TimestampTolerance is the maximum difference between the timestamp of a request and the server time
+// accepted by `VerifyTimestamp`, in both directions.
+const TimestampTolerance = 150 * time.Second
+
+// RequestAttributes returns a scratchpad for values computed while handling the request, e.g. by a
+// request interceptor for the handler. Unlike session attributes they are never sent to the Alexa service
+// and only live as long as the request.
+func (r *EchoRequest) RequestAttributes() map[string]interface{} {
+	if r.attributes == nil {
+		r.attributes = make(map[string]interface{})
+	}
+
+	return r.attributes
+}
+
 // VerifyTimestamp will parse the timestamp in the EchoRequest and verify that it is in the correct
-// format and is not too old. True will be returned if the timestamp is valid; false otherwise.
+// format and is within `TimestampTolerance` of the server time. Timestamps slightly in the future are
+// accepted in case the server clock is behind. True will be returned if the timestamp is valid; false otherwise.
 func (r *EchoRequest) VerifyTimestamp() bool {
-	reqTimestamp, _ := time.Parse("2006-01-02T15:04:05Z", r.Request.Timestamp)
-	if time.Since(reqTimestamp) < time.Duration(150)*time.Second {
+	reqTimestamp, err := time.Parse("2006-01-02T15:04:05Z", r.Request.Timestamp)
+	if err != nil {
+		return false
+	}
+
+	age := time.Since(reqTimestamp)
+	if age < TimestampTolerance && age > -TimestampTolerance {
 		return true
 	}
 
@@ -46,13 +137,40 @@ func (r *EchoRequest) VerifyAppID(myAppID string) bool {
 	return false
 }
 
+// Returns the application ID of the request, preferring the context over the session.
+func (r *EchoRequest) applicationID() string {
+	if r.Context.System.Application.ApplicationID != "" {
+		return r.Context.System.Application.ApplicationID
+	}
+
+	return r.Session.Application.ApplicationID
+}
+
+// GetRequestID is a convenience method for getting the unique ID of the request out of an EchoRequest.
+func (r *EchoRequest) GetRequestID() string {
+	return r.Request.RequestID
+}
+
+// Logger returns a logger writing to the output of the standard logger, prefixing every message with the
+// request and session ID, so all log lines of a single interaction can be correlated.
+func (r *EchoRequest) Logger() *log.Logger {
+	prefix := fmt.Sprintf("requestId=%s sessionId=%s ", r.GetRequestID(), r.GetSessionID())
+	return log.New(log.Writer(), prefix, log.Flags())
+}
+
 // GetSessionID is a convenience method for getting the session ID out of an EchoRequest.
 func (r *EchoRequest) GetSessionID() string {
 	return r.Session.SessionID
 }
 
 // GetUserID is a convenience method for getting the user identifier out of an EchoRequest.
+// The user ID from the context is preferred as it is also sent with requests outside of a session,
+// the one from the session is used as a fallback.
 func (r *EchoRequest) GetUserID() string {
+	if r.Context.System.User.UserID != "" {
+		return r.Context.System.User.UserID
+	}
+
 	return r.Session.User.UserID
 }
 
@@ -70,32 +188,197 @@ func (r *EchoRequest) GetIntentName() string {
 	return r.GetRequestType()
 }
 
+// GetIntent returns the intent of the request. When the dialog was delegated to the Alexa service, the
+// intent contains the slot values and confirmation statuses collected so far.
+func (r *EchoRequest) GetIntent() EchoIntent {
+	return r.Request.Intent
+}
+
+// GetDialogState returns the state of the dialog of the request, one of `dialog.Started`, `dialog.InProgress`
+// or `dialog.Completed`. It is empty if the skill has no dialog model for the intent.
+func (r *EchoRequest) GetDialogState() string {
+	return r.Request.DialogState
+}
+
+// IsDialogComplete is true once the Alexa service has collected and confirmed all required slots of
+// a delegated dialog, so the handler can fulfill the intent.
+func (r *EchoRequest) IsDialogComplete() bool {
+	return r.GetDialogState() == dialog.Completed
+}
+
 // GetSlotValue is a convenience method for getting the value of the specified slot out of an EchoRequest
 // as a string. An error is returned if a slot with that value is not found in the request.
 func (r *EchoRequest) GetSlotValue(slotName string) (string, error) {
-	slot, err := r.GetSlot(slotName)
+	slot, ok := r.GetSlot(slotName)
 
-	if err != nil {
-		return "", err
+	if !ok {
+		return "", errors.New("slot name not found")
 	}
 
 	return slot.Value, nil
 }
 
-// GetSlot will return an EchoSlot from the EchoRequest with the given name.
-func (r *EchoRequest) GetSlot(slotName string) (EchoSlot, error) {
-	if _, ok := r.Request.Intent.Slots[slotName]; ok {
-		return r.Request.Intent.Slots[slotName], nil
+// GetSlot will return the Slot from the EchoRequest with the given name. False is returned
+// if the intent has no slot with that name.
+func (r *EchoRequest) GetSlot(slotName string) (*Slot, bool) {
+	slot, ok := r.Request.Intent.Slots[slotName]
+	if !ok {
+		return nil, false
 	}
 
-	return EchoSlot{}, errors.New("slot name not found")
+	return &slot, true
+}
+
+// GetRawSlotValue returns the value of the slot exactly as it was recognized, without looking at entity
+// resolutions. This is the way to read free-text slots like AMAZON.SearchQuery, which never have resolutions.
+// False is returned if the slot is missing from the request or has no value.
+func (r *EchoRequest) GetRawSlotValue(slotName string) (string, bool) {
+	slot, ok := r.Request.Intent.Slots[slotName]
+	if !ok || slot.Value == "" {
+		return "", false
+	}
+
+	return slot.Value, true
 }
 
 // AllSlots will return a map of all the slots in the EchoRequest mapped by their name.
-func (r *EchoRequest) AllSlots() map[string]EchoSlot {
+func (r *EchoRequest) AllSlots() map[string]Slot {
 	return r.Request.Intent.Slots
 }
 
+// GetSlots returns a copy of all slots of the intent, mapped by their name, including their values and
+// resolutions. Unlike `AllSlots`, changes to the returned map don't affect the request.
+func (r *EchoRequest) GetSlots() map[string]Slot {
+	slots := make(map[string]Slot, len(r.Request.Intent.Slots))
+	for name, slot := range r.Request.Intent.Slots {
+		slots[name] = slot
+	}
+
+	return slots
+}
+
+// GetIntentJSON returns the intent of the request as it was received from the Alexa service, so skills with
+// complex slot structures can unmarshal it into their own types. False is returned if the request has no intent.
+func (r *EchoRequest) GetIntentJSON() (json.RawMessage, bool) {
+	if len(r.Request.Intent.raw) == 0 {
+		return nil, false
+	}
+
+	return r.Request.Intent.raw, true
+}
+
+// RequiresMoreSlots returns the names of the provided required slots which are still missing from the
+// request, have no value yet, or whose value was denied by the end user. Handlers using auto delegation
+// can respond with a `dialog.Delegate` directive while the returned slice is not empty.
+func (r *EchoRequest) RequiresMoreSlots(required []string) []string {
+	missing := []string{}
+	for _, name := range required {
+		slot, ok := r.Request.Intent.Slots[name]
+		if !ok || slot.Value == "" || slot.ConfirmationStatus == ConfDenied {
+			missing = append(missing, name)
+		}
+	}
+
+	return missing
+}
+
+// UnconfirmedSlots returns the names of the provided slots needing confirmation which have a value that
+// the end user has neither confirmed nor denied yet, in the order provided. Handlers can respond with a
+// `dialog.ConfirmSlot` directive for the first of them. Slots without a value are left out, see `RequiresMoreSlots`.
+func (r *EchoRequest) UnconfirmedSlots(needConfirmation []string) []string {
+	unconfirmed := []string{}
+	for _, name := range needConfirmation {
+		slot, ok := r.Request.Intent.Slots[name]
+		if !ok || slot.Value == "" {
+			continue
+		}
+
+		if slot.ConfirmationStatus == ConfNone || slot.ConfirmationStatus == "" {
+			unconfirmed = append(unconfirmed, name)
+		}
+	}
+
+	return unconfirmed
+}
+
+// GetConsentToken returns the consent token from the request if the end user has granted the skill
+// permissions. The token from the context is preferred over the one in the session. False is returned
+// if no permissions have been granted, in which case a consent card should be sent to the user.
+func (r *EchoRequest) GetConsentToken() (string, bool) {
+	if p := r.Context.System.User.Permissions; p != nil && p.ConsentToken != "" {
+		return p.ConsentToken, true
+	}
+
+	if p := r.Session.User.Permissions; p != nil && p.ConsentToken != "" {
+		return p.ConsentToken, true
+	}
+
+	return "", false
+}
+
+// GetUnitID returns the ID of the unit (e.g. a room in Alexa for Business) the device is registered to.
+// False is returned if the request was not sent from a device that belongs to a unit.
+func (r *EchoRequest) GetUnitID() (string, bool) {
+	if u := r.Context.System.Unit; u != nil && u.UnitID != "" {
+		return u.UnitID, true
+	}
+
+	return "", false
+}
+
+// GetPersistentUnitID returns the ID of the unit the device is registered to which, unlike the one
+// returned by `GetUnitID`, stays the same if the skill is disabled and enabled again.
+func (r *EchoRequest) GetPersistentUnitID() (string, bool) {
+	if u := r.Context.System.Unit; u != nil && u.PersistentUnitID != "" {
+		return u.PersistentUnitID, true
+	}
+
+	return "", false
+}
+
+// GetContextObject returns the raw JSON of the top level context object with the given name, e.g.
+// "Geolocation" or "Viewport". This can be used to read context objects that are not modelled by `EchoContext`.
+func (r *EchoRequest) GetContextObject(name string) (json.RawMessage, bool) {
+	obj, ok := r.Context.raw[name]
+
+	return obj, ok
+}
+
+// GetAPIRequestName returns the name of the API invoked by an Alexa Conversations dialog. An empty string
+// is returned if the request is not a `Dialog.API.Invoked` request.
+func (r *EchoRequest) GetAPIRequestName() string {
+	if r.Request.APIRequest == nil {
+		return ""
+	}
+
+	return r.Request.APIRequest.Name
+}
+
+// GetAPIRequestArguments returns the raw JSON of all arguments passed to the API invoked by an Alexa
+// Conversations dialog, keyed by argument name.
+func (r *EchoRequest) GetAPIRequestArguments() map[string]json.RawMessage {
+	if r.Request.APIRequest == nil {
+		return nil
+	}
+
+	return r.Request.APIRequest.Arguments
+}
+
+// IsSimulator guesses whether the request was sent from the Alexa simulator in the developer console
+// rather than from a real device, based on the request missing the device object or its device ID.
+// The supported interfaces are not considered: real devices send an empty list for skills that don't
+// enable any interfaces. This is only a heuristic, requests from tools like the ASK CLI look the same
+// and a simulator may send a device ID. Use it to degrade gracefully, never for security.
+func (r *EchoRequest) IsSimulator() bool {
+	return r.Context.System.Device.DeviceID == ""
+}
+
+// GetSkillEventBody returns the raw body of an `AlexaSkillEvent.*` request, e.g. the accepted permissions
+// of a `AlexaSkillEvent.SkillPermissionAccepted` event. It is empty for all other request types.
+func (r *EchoRequest) GetSkillEventBody() json.RawMessage {
+	return r.Request.Body
+}
+
 // Locale returns the locale specified in the request.
 func (r *EchoRequest) Locale() string {
 	return r.Request.Locale
@@ -105,12 +388,13 @@ func (r *EchoRequest) Locale() string {
 
 // NewEchoResponse will construct a new response instance with the required metadata and an empty speech string.
 // By default the response will indicate that the session should be ended. Use the `EndSession(bool)` method if the
-// session should be left open.
+// session should be left open. Responses carrying Dialog or APL ExecuteCommands directives keep the session open
+// unless `EndSession` is called.
 func NewEchoResponse() *EchoResponse {
 	er := &EchoResponse{
 		Version: "1.0",
 		Response: EchoRespBody{
-			ShouldEndSession: true,
+			ShouldEndSession: boolPtr(true),
 		},
 		SessionAttributes: make(map[string]interface{}),
 	}
@@ -125,6 +409,7 @@ func (r *EchoResponse) OutputSpeech(text string) *EchoResponse {
 		Type: "PlainText",
 		Text: text,
 	}
+	r.speechText = ""
 
 	return r
 }
@@ -141,49 +426,114 @@ func (r *EchoResponse) OutputSpeechSSML(text string) *EchoResponse {
 		Type: "SSML",
 		SSML: text,
 	}
+	r.speechText = ""
+
+	return r
+}
+
+// OutputSpeechRich will set the SSML as the output speech and keep the plain text version of it for
+// logging, see `SpeechText`. Alexa only ever speaks the SSML, the plain text is not sent to the Alexa service.
+func (r *EchoResponse) OutputSpeechRich(text, ssml string) *EchoResponse {
+	r.OutputSpeechSSML(ssml)
+	r.speechText = text
 
 	return r
 }
 
+// SpeechText returns a plain text version of the output speech: the text passed to `OutputSpeechRich`
+// or the text of a plain text output speech. It is empty for SSML set with `OutputSpeechSSML`.
+func (r *EchoResponse) SpeechText() string {
+	if r.speechText != "" {
+		return r.speechText
+	}
+
+	if r.Response.OutputSpeech != nil && r.Response.OutputSpeech.Type == "PlainText" {
+		return r.Response.OutputSpeech.Text
+	}
+
+	return ""
+}
+
 // SimpleCard will indicate that a card should be included in the Alexa companion app as part of the response.
-// The card will be shown with the provided title and content.
+// The card will be shown with the provided title and content. A response holds a single card: the last call
+// of any card method wins and replaces the card set before, which is logged.
 func (r *EchoResponse) SimpleCard(title string, content string) *EchoResponse {
-	r.Response.Card = &EchoRespPayload{
+	r.setCard(&EchoRespPayload{
 		Type:    "Simple",
 		Title:   title,
 		Content: content,
-	}
+	})
 
 	return r
 }
 
+// Set the card of the response, logging when it replaces another card or is combined with AudioPlayer
+// directives. Cards are dropped from responses to `AudioPlayer.*` requests, see `normalizeAudioPlayerResponse`.
+func (r *EchoResponse) setCard(card *EchoRespPayload) {
+	if r.Response.Card != nil {
+		log.Printf("%s card replaced by %s card, a response holds a single card", r.Response.Card.Type, card.Type)
+	}
+
+	for _, d := range r.Response.Directives {
+		if strings.HasPrefix(d.DirectiveType(), "AudioPlayer.") {
+			log.Printf("%s card set alongside %s directive, it is dropped if the response answers an AudioPlayer request", card.Type, d.DirectiveType())
+			break
+		}
+	}
+
+	r.Response.Card = card
+}
+
 // StandardCard will indicate that a card should be shown in the Alexa companion app as part of the response.
 // The card shown will include the provided title and content as well as images loaded from the locations provided
-// as remote locations.
+// as remote locations. The Alexa app only loads images over https, image URLs using another scheme are
+// left out of the card and a warning is logged.
 func (r *EchoResponse) StandardCard(title string, content string, smallImg string, largeImg string) *EchoResponse {
-	r.Response.Card = &EchoRespPayload{
+	card := &EchoRespPayload{
 		Type:    "Standard",
 		Title:   title,
 		Content: content,
 	}
 
 	if smallImg != "" {
-		r.Response.Card.Image.SmallImageURL = smallImg
+		if err := requireHTTPS(smallImg); err != nil {
+			log.Println("Removed small card image:", err)
+		} else {
+			card.Image.SmallImageURL = smallImg
+		}
 	}
 
 	if largeImg != "" {
-		r.Response.Card.Image.LargeImageURL = largeImg
+		if err := requireHTTPS(largeImg); err != nil {
+			log.Println("Removed large card image:", err)
+		} else {
+			card.Image.LargeImageURL = largeImg
+		}
 	}
 
+	r.setCard(card)
+
+	return r
+}
+
+// AskForPermissionsConsentCard will show a card in the Alexa companion app asking the end user to grant
+// the skill the provided permissions, e.g. "read::alexa:household:list". This should be sent when an
+// Alexa service API call fails with a `PermissionError`.
+func (r *EchoResponse) AskForPermissionsConsentCard(permissions []string) *EchoResponse {
+	r.setCard(&EchoRespPayload{
+		Type:        "AskForPermissionsConsent",
+		Permissions: permissions,
+	})
+
 	return r
 }
 
 // LinkAccountCard is used to indicate that account linking still needs to be completed to continue
 // using the Alexa skill. This will force an account linking card to be shown in the user's companion app.
 func (r *EchoResponse) LinkAccountCard() *EchoResponse {
-	r.Response.Card = &EchoRespPayload{
+	r.setCard(&EchoRespPayload{
 		Type: "LinkAccount",
-	}
+	})
 
 	return r
 }
@@ -206,17 +556,59 @@ func (r *EchoResponse) RepromptSSML(text string) *EchoResponse {
 	r.Response.Reprompt = &EchoReprompt{
 		OutputSpeech: EchoRespPayload{
 			Type: "SSML",
-			Text: text,
+			SSML: text,
 		},
 	}
 
 	return r
 }
 
+// GetOutputSpeech returns the output speech of the response, or nil if none is set.
+func (r *EchoResponse) GetOutputSpeech() *EchoRespPayload {
+	return r.Response.OutputSpeech
+}
+
+// GetReprompt returns the output speech of the reprompt, or nil if no reprompt is set. Its type is
+// independent of the output speech, e.g. the speech may be SSML while the reprompt is plain text.
+func (r *EchoResponse) GetReprompt() *EchoRespPayload {
+	if r.Response.Reprompt == nil {
+		return nil
+	}
+
+	return &r.Response.Reprompt.OutputSpeech
+}
+
 // EndSession is a convenience method for setting the flag in the response that will
 // indicate if the session between the end user's device and the skillserver should be closed.
 func (r *EchoResponse) EndSession(flag bool) *EchoResponse {
-	r.Response.ShouldEndSession = flag
+	r.Response.ShouldEndSession = &flag
+	r.Response.sessionFlagSet = true
+
+	return r
+}
+
+// KeepSessionOpenSilently will omit the `shouldEndSession` flag from the response. The session stays
+// open without the device listening for a reply, which is needed for responses consisting only of
+// directives, e.g. APL documents waiting for a user event.
+func (r *EchoResponse) KeepSessionOpenSilently() *EchoResponse {
+	r.Response.ShouldEndSession = nil
+	r.Response.sessionFlagSet = true
+
+	return r
+}
+
+// DeleteSessionAttribute will remove the attribute with the given key from the session attributes
+// that are sent back to the Alexa service. Deleting a key that is not set is a no-op.
+func (r *EchoResponse) DeleteSessionAttribute(key string) *EchoResponse {
+	delete(r.SessionAttributes, key)
+
+	return r
+}
+
+// ClearSessionAttributes will remove all session attributes from the response, so none of them
+// are carried over to the next request in the session.
+func (r *EchoResponse) ClearSessionAttributes() *EchoResponse {
+	r.SessionAttributes = make(map[string]interface{})
 
 	return r
 }
@@ -227,7 +619,7 @@ func (r *EchoResponse) EndSession(flag bool) *EchoResponse {
 // Multiple directives can be returned by calling the method in chain
 // (eg. RespondToIntent(...).RespondToIntent(...), each RespondToIntent call appends the
 // data to Directives array and will return the same at the end.
-func (r *EchoResponse) RespondToIntent(name dialog.Type, intent *EchoIntent, slot *EchoSlot) *EchoResponse {
+func (r *EchoResponse) RespondToIntent(name dialog.Type, intent *EchoIntent, slot *Slot) *EchoResponse {
 	directive := EchoDirective{Type: name}
 	if intent != nil && name == dialog.ConfirmIntent {
 		directive.IntentToConfirm = intent.Name
@@ -246,6 +638,155 @@ func (r *EchoResponse) RespondToIntent(name dialog.Type, intent *EchoIntent, slo
 	return r
 }
 
+// ClearDirectives will remove all directives from the response, e.g. to fall back to plain speech after
+// finding out the device doesn't support a display.
+func (r *EchoResponse) ClearDirectives() *EchoResponse {
+	r.Response.Directives = nil
+
+	return r
+}
+
+// RemoveDirective will remove all directives of the given type from the response. The order of the
+// remaining directives is preserved.
+func (r *EchoResponse) RemoveDirective(directiveType string) *EchoResponse {
+	directives := r.Response.Directives[:0]
+	for _, d := range r.Response.Directives {
+		if d.DirectiveType() != directiveType {
+			directives = append(directives, d)
+		}
+	}
+
+	if len(directives) == 0 {
+		directives = nil
+	}
+	r.Response.Directives = directives
+
+	return r
+}
+
+// DialogAPIResponse sets the result of an API invoked by an Alexa Conversations dialog. The result has to match
+// the return type of the API definition. Alexa Conversations reads it from the `apiResponse` field of the
+// response, no directive is needed unless the skill hands the dialog to another handler. There is no
+// result directive and no status: an API that fails answers with speech instead of a result.
+func (r *EchoResponse) DialogAPIResponse(result json.RawMessage) *EchoResponse {
+	r.Response.APIResponse = result
+
+	return r
+}
+
+// SetRaw sets a field of the `response` object that is not modelled by EchoRespBody, e.g. one recently
+// added by Amazon. The value is merged in when the response is serialized and replaces a modelled field
+// of the same name. A nil value removes the field again.
+func (r *EchoResponse) SetRaw(key string, value json.RawMessage) *EchoResponse {
+	if value == nil {
+		delete(r.Response.raw, key)
+		return r
+	}
+
+	if r.Response.raw == nil {
+		r.Response.raw = make(map[string]json.RawMessage)
+	}
+	r.Response.raw[key] = value
+
+	return r
+}
+
+// AddDialogDelegateRequestDirective will add a directive to the response that hands the control of the dialog to
+// the given target, "AMAZON.Conversations" or "skill". The period sets until when the target stays in control,
+// e.g. "EXPLICIT_RETURN". The updated request, if not nil, is passed on to the target. This differs from
+// `dialog.Delegate`, which lets the Alexa service fill the slots of a classic dialog model.
+func (r *EchoResponse) AddDialogDelegateRequestDirective(target, period string, updatedRequest json.RawMessage) *EchoResponse {
+	r.Response.Directives = append(r.Response.Directives, &DialogDelegateRequestDirective{
+		Type:           dialog.DelegateRequest,
+		Target:         target,
+		Period:         DialogDelegatePeriod{Until: period},
+		UpdatedRequest: updatedRequest,
+	})
+
+	return r
+}
+
+// AddDirective will append the directive to the response's directives. Use it for directives this package
+// doesn't provide a builder for, implementing the Directive interface with a type of your own. A nil
+// directive is ignored, including a nil pointer of a directive type, e.g. `(*EchoDirective)(nil)`.
+func (r *EchoResponse) AddDirective(d Directive) *EchoResponse {
+	if isNilDirective(d) {
+		return r
+	}
+
+	r.Response.Directives = append(r.Response.Directives, d)
+
+	return r
+}
+
+// InsertDirectiveAt will insert the directive at the given position in the response's directives.
+// Directives that were already at or after that position are shifted back by one. An index less
+// than zero inserts at the front and an index past the end appends the directive. A nil directive is ignored,
+// like by `AddDirective`.
+func (r *EchoResponse) InsertDirectiveAt(index int, d Directive) *EchoResponse {
+	if isNilDirective(d) {
+		return r
+	}
+
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(r.Response.Directives) {
+		r.Response.Directives = append(r.Response.Directives, d)
+		return r
+	}
+
+	r.Response.Directives = append(r.Response.Directives, nil)
+	copy(r.Response.Directives[index+1:], r.Response.Directives[index:])
+	r.Response.Directives[index] = d
+
+	return r
+}
+
+// Returns whether the directive is nil or holds a nil value, which would panic once its type is read.
+func isNilDirective(d Directive) bool {
+	if d == nil {
+		return true
+	}
+
+	switch v := reflect.ValueOf(d); v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+// CheckLimits verifies the spoken text of the output speech and the reprompt as well as the size of the
+// whole serialized response against the provided limits. A limit of zero is not checked.
+func (r *EchoResponse) CheckLimits(limits ResponseLimits) error {
+	if limits.MaxSpeechLength > 0 {
+		if r.Response.OutputSpeech != nil && r.Response.OutputSpeech.speechLength() > limits.MaxSpeechLength {
+			return fmt.Errorf("%w: output speech is longer than %d characters", ErrSpeechTooLong, limits.MaxSpeechLength)
+		}
+
+		if r.Response.Reprompt != nil && r.Response.Reprompt.OutputSpeech.speechLength() > limits.MaxSpeechLength {
+			return fmt.Errorf("%w: reprompt is longer than %d characters", ErrSpeechTooLong, limits.MaxSpeechLength)
+		}
+	}
+
+	if limits.MaxResponseSize > 0 {
+		jsonStr, err := r.String()
+		if err != nil {
+			return err
+		}
+
+		if len(jsonStr) > limits.MaxResponseSize {
+			return fmt.Errorf("%w: response is %d bytes, limit is %d", ErrResponseTooLarge, len(jsonStr), limits.MaxResponseSize)
+		}
+	}
+
+	return nil
+}
+
+// String serializes the response to the JSON sent back to the Alexa service. The output is deterministic:
+// map keys (e.g. of session attributes) are sorted and directives keep the order they were added in, so
+// marshaling the same response twice yields identical bytes, which makes responses usable in golden file tests.
 func (r *EchoResponse) String() ([]byte, error) {
 	jsonStr, err := json.Marshal(r)
 	if err != nil {
@@ -255,6 +796,28 @@ func (r *EchoResponse) String() ([]byte, error) {
 	return jsonStr, nil
 }
 
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// requireHTTPS checks that the provided URL is absolute and uses the https scheme.
+func requireHTTPS(rawURL string) error {
+	link, err := url.Parse(rawURL)
+	if err != nil || link.Scheme != "https" || link.Host == "" {
+		return fmt.Errorf("%w: %q", ErrInsecureURL, rawURL)
+	}
+
+	return nil
+}
+
+func (p *EchoRespPayload) speechLength() int {
+	if p.Type == "SSML" {
+		return utf8.RuneCountInString(p.SSML)
+	}
+
+	return utf8.RuneCountInString(p.Text)
+}
+
 // Request Types
 
 // EchoRequest represents all fields sent from the Alexa service to the skillserver.
@@ -264,6 +827,8 @@ type EchoRequest struct {
 	Session EchoSession `json:"session"`
 	Request EchoReqBody `json:"request"`
 	Context EchoContext `json:"context"`
+
+	attributes map[string]interface{} // See `RequestAttributes`.
 }
 
 // EchoSession contains information about the ongoing session between the Alexa server and
@@ -276,8 +841,9 @@ type EchoSession struct {
 	} `json:"application"`
 	Attributes map[string]interface{} `json:"attributes"`
 	User       struct {
-		UserID      string `json:"userId"`
-		AccessToken string `json:"accessToken,omitempty"`
+		UserID      string           `json:"userId"`
+		AccessToken string           `json:"accessToken,omitempty"`
+		Permissions *EchoPermissions `json:"permissions,omitempty"`
 	} `json:"user"`
 }
 
@@ -286,12 +852,71 @@ type EchoSession struct {
 type EchoContext struct {
 	System struct {
 		Device struct {
-			DeviceID string `json:"deviceId,omitempty"`
+			DeviceID            string                     `json:"deviceId,omitempty"`
+			SupportedInterfaces map[string]json.RawMessage `json:"supportedInterfaces,omitempty"`
 		} `json:"device,omitempty"`
 		Application struct {
 			ApplicationID string `json:"applicationId,omitempty"`
 		} `json:"application,omitempty"`
+		User struct {
+			UserID      string           `json:"userId,omitempty"`
+			AccessToken string           `json:"accessToken,omitempty"`
+			Permissions *EchoPermissions `json:"permissions,omitempty"`
+		} `json:"user,omitempty"`
+		Unit *struct {
+			UnitID           string `json:"unitId,omitempty"`
+			PersistentUnitID string `json:"persistentUnitId,omitempty"`
+		} `json:"unit,omitempty"`
+		Person *struct {
+			PersonID    string `json:"personId,omitempty"`
+			AccessToken string `json:"accessToken,omitempty"`
+		} `json:"person,omitempty"`
+		APIEndpoint    string `json:"apiEndpoint,omitempty"`
+		APIAccessToken string `json:"apiAccessToken,omitempty"`
 	} `json:"System,omitempty"`
+	Geolocation *Geolocation `json:"Geolocation,omitempty"`
+	Automotive  *Automotive  `json:"Automotive,omitempty"`
+
+	raw map[string]json.RawMessage // All top level context objects, including the ones not modelled above.
+}
+
+// UnmarshalJSON decodes the context and keeps the raw JSON of every top level object around so
+// it can be retrieved with `EchoRequest.GetContextObject`.
+func (c *EchoContext) UnmarshalJSON(data []byte) error {
+	type echoContext EchoContext
+	var ctx echoContext
+	if err := json.Unmarshal(data, &ctx); err != nil {
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*c = EchoContext(ctx)
+	c.raw = raw
+
+	return nil
+}
+
+// EchoPermissions contains the consent token that is included in a request once the end user has
+// granted the skill one or more permissions, e.g. access to the device address.
+type EchoPermissions struct {
+	ConsentToken string                         `json:"consentToken,omitempty"`
+	Scopes       map[string]EchoPermissionScope `json:"scopes,omitempty"` // Keyed by scope, e.g. "alexa::devices:all:geolocation:read".
+}
+
+// EchoPermissionScope is the status of a single permission scope, "GRANTED" or "DENIED".
+type EchoPermissionScope struct {
+	Status string `json:"status"`
+}
+
+// EchoRequestError describes the error that ended a session or stopped the playback, sent with
+// `SessionEndedRequest` and `AudioPlayer.PlaybackFailed` requests.
+type EchoRequestError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
 }
 
 // EchoReqBody contains all data related to the type of request sent.
@@ -303,24 +928,110 @@ type EchoReqBody struct {
 	Reason      string     `json:"reason,omitempty"`
 	Locale      string     `json:"locale,omitempty"`
 	DialogState string     `json:"dialogState,omitempty"`
+
+	// Launch, session ended and session resumed requests
+	ShouldLinkResultBeReturned bool              `json:"shouldLinkResultBeReturned,omitempty"`
+	Task                       json.RawMessage   `json:"task,omitempty"`
+	Error                      *EchoRequestError `json:"error,omitempty"`
+	Cause                      json.RawMessage   `json:"cause,omitempty"`
+
+	// AudioPlayer requests
+	OffsetInMilliseconds int             `json:"offsetInMilliseconds,omitempty"`
+	CurrentPlaybackState json.RawMessage `json:"currentPlaybackState,omitempty"`
+
+	// Alexa.Presentation.APL user events
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	Source     json.RawMessage `json:"source,omitempty"`
+	Components json.RawMessage `json:"components,omitempty"`
+
+	// Alexa Conversations
+	APIRequest *EchoAPIRequest `json:"apiRequest,omitempty"`
+
+	// Connections responses
+	Name    string                 `json:"name,omitempty"`
+	Status  *EchoConnectionsStatus `json:"status,omitempty"`
+	Token   string                 `json:"token,omitempty"`
+	Payload json.RawMessage        `json:"payload,omitempty"`
+
+	// Skill events
+	Body                json.RawMessage `json:"body,omitempty"`
+	EventCreationTime   string          `json:"eventCreationTime,omitempty"`
+	EventPublishingTime string          `json:"eventPublishingTime,omitempty"`
+
+	// Alexa.Presentation.HTML and skill messaging messages
+	Message json.RawMessage `json:"message,omitempty"`
+
+	// Alexa.Presentation.APL list data requests
+	CorrelationToken string `json:"correlationToken,omitempty"`
+	ListID           string `json:"listId,omitempty"`
+	ListVersion      int    `json:"listVersion,omitempty"`
+	StartIndex       int    `json:"startIndex,omitempty"`
+	Count            int    `json:"count,omitempty"`
+	PageToken        string `json:"pageToken,omitempty"`
+}
+
+// EchoAPIRequest contains the API definition invoked by an Alexa Conversations dialog as part
+// of a `Dialog.API.Invoked` request. The arguments are keyed by their name in the API definition.
+type EchoAPIRequest struct {
+	Name      string                     `json:"name"`
+	Arguments map[string]json.RawMessage `json:"arguments,omitempty"`
 }
 
 // EchoIntent represents the intent that is sent as part of an EchoRequest. This includes
 // the name of the intent configured in the Alexa developers dashboard as well as any slots
 // and the optional confirmation status if one is needed to complete an intent.
 type EchoIntent struct {
-	Name               string              `json:"name"`
-	Slots              map[string]EchoSlot `json:"slots"`
-	ConfirmationStatus ConfirmationStatus  `json:"confirmationStatus"`
+	Name               string             `json:"name"`
+	Slots              map[string]Slot    `json:"slots"`
+	ConfirmationStatus ConfirmationStatus `json:"confirmationStatus"`
+
+	raw json.RawMessage // The intent as it was received, see `EchoRequest.GetIntentJSON`.
+}
+
+// UnmarshalJSON decodes the intent and keeps a copy of the raw JSON for custom parsing.
+func (i *EchoIntent) UnmarshalJSON(data []byte) error {
+	type echoIntent EchoIntent
+	var intent echoIntent
+	if err := json.Unmarshal(data, &intent); err != nil {
+		return err
+	}
+
+	*i = EchoIntent(intent)
+	i.raw = append(json.RawMessage(nil), data...)
+
+	return nil
 }
 
-// EchoSlot represents variable values that can be sent that were specified by the end user
+// Slot represents variable values that can be sent that were specified by the end user
 // when invoking the Alexa application.
-type EchoSlot struct {
+type Slot struct {
 	Name               string             `json:"name"`
 	Value              string             `json:"value"`
 	Resolutions        EchoResolution     `json:"resolutions"`
 	ConfirmationStatus ConfirmationStatus `json:"confirmationStatus"`
+	Source             string             `json:"source,omitempty"`
+}
+
+// EchoSlot is the former name of Slot and is kept for compatibility.
+type EchoSlot = Slot
+
+// FirstResolvedValue returns the name and ID of the first value an authority successfully resolved
+// the slot to. For custom slot types with synonyms this is the canonical value the end user's
+// utterance matched. False is returned if no authority matched the slot value.
+func (s *Slot) FirstResolvedValue() (value, id string, ok bool) {
+	for _, authority := range s.Resolutions.ResolutionsPerAuthority {
+		if authority.Status.Code != "ER_SUCCESS_MATCH" {
+			continue
+		}
+
+		for _, v := range authority.Values {
+			if resolved, found := v["value"]; found {
+				return resolved.Name, resolved.ID, true
+			}
+		}
+	}
+
+	return "", "", false
 }
 
 // EchoResolution contains the results of entity resolutions when it relates to slots and how
@@ -353,17 +1064,76 @@ type EchoResponse struct {
 	Version           string                 `json:"version"`
 	SessionAttributes map[string]interface{} `json:"sessionAttributes,omitempty"`
 	Response          EchoRespBody           `json:"response"`
+
+	localizer  Localizer // Set by the EchoApplication handling the request, see `Speak`.
+	speechText string    // Plain text version of SSML output speech, see `OutputSpeechRich`.
 }
 
 // EchoRespBody contains the body of the response to be sent back to the Alexa service.
 // This includes things like the text that should be spoken or any cards that should
 // be shown in the Alexa companion app.
 type EchoRespBody struct {
-	OutputSpeech     *EchoRespPayload `json:"outputSpeech,omitempty"`
-	Card             *EchoRespPayload `json:"card,omitempty"`
-	Reprompt         *EchoReprompt    `json:"reprompt,omitempty"` // Pointer so it's dropped if empty in JSON response.
-	ShouldEndSession bool             `json:"shouldEndSession"`
-	Directives       []*EchoDirective `json:"directives,omitempty"`
+	OutputSpeech     *EchoRespPayload      `json:"outputSpeech,omitempty"`
+	Card             *EchoRespPayload      `json:"card,omitempty"`
+	Reprompt         *EchoReprompt         `json:"reprompt,omitempty"`         // Pointer so it's dropped if empty in JSON response.
+	ShouldEndSession *bool                 `json:"shouldEndSession,omitempty"` // Pointer so it can be left out of the JSON response.
+	Directives       []Directive           `json:"directives,omitempty"`       // Serialized in the order they were added.
+	APIResponse      json.RawMessage       `json:"apiResponse,omitempty"`
+	CanFulfillIntent *EchoCanFulfillIntent `json:"canFulfillIntent,omitempty"`
+
+	raw            map[string]json.RawMessage // Fields set with `EchoResponse.SetRaw`.
+	sessionFlagSet bool                       // Whether the session flag was set with `EndSession` or `KeepSessionOpenSilently`.
+}
+
+// MarshalJSON encodes the response body and merges in the fields set with `EchoResponse.SetRaw`.
+func (b EchoRespBody) MarshalJSON() ([]byte, error) {
+	b.ShouldEndSession = b.sessionFlag()
+
+	type echoRespBody EchoRespBody
+	data, err := json.Marshal(echoRespBody(b))
+	if err != nil || len(b.raw) == 0 {
+		return data, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range b.raw {
+		fields[key] = value
+	}
+
+	return json.Marshal(fields)
+}
+
+// Returns the session flag to send. The Alexa service rejects responses ending the session while they carry
+// Dialog directives, which wait for an answer of the end user, or APL ExecuteCommands directives. Unless the
+// flag was set explicitly, the default of `NewEchoResponse` is replaced by false for Dialog directives and
+// left out for ExecuteCommands.
+func (b EchoRespBody) sessionFlag() *bool {
+	if b.ShouldEndSession == nil || !*b.ShouldEndSession {
+		return b.ShouldEndSession
+	}
+
+	flag := b.ShouldEndSession
+	for _, d := range b.Directives {
+		directiveType := d.DirectiveType()
+		switch {
+		case strings.HasPrefix(directiveType, "Dialog.") && directiveType != "Dialog.UpdateDynamicEntities":
+			flag = boolPtr(false)
+		case directiveType == APLExecuteCommands && flag != nil && *flag:
+			flag = nil
+		default:
+			continue
+		}
+
+		if b.sessionFlagSet {
+			log.Printf("Session ended with %s directive, the Alexa service rejects the response", directiveType)
+			return b.ShouldEndSession
+		}
+	}
+
+	return flag
 }
 
 // EchoReprompt contains speech that should be spoken back to the end user to retrieve
@@ -382,12 +1152,22 @@ type EchoRespImage struct {
 // EchoRespPayload contains the interesting parts of the Echo response including text to be spoken,
 // card attributes, and images.
 type EchoRespPayload struct {
-	Type    string        `json:"type,omitempty"`
-	Title   string        `json:"title,omitempty"`
-	Text    string        `json:"text,omitempty"`
-	SSML    string        `json:"ssml,omitempty"`
-	Content string        `json:"content,omitempty"`
-	Image   EchoRespImage `json:"image,omitempty"`
+	Type        string        `json:"type,omitempty"`
+	Title       string        `json:"title,omitempty"`
+	Text        string        `json:"text,omitempty"`
+	SSML        string        `json:"ssml,omitempty"`
+	Content     string        `json:"content,omitempty"`
+	Image       EchoRespImage `json:"image,omitempty"`
+	Permissions []string      `json:"permissions,omitempty"`
+}
+
+// Directive is implemented by every directive that can be sent back as part of a response.
+// Directives are serialized in the order in which they were added to the response, which matters
+// for directives that depend on each other (e.g. rendering a document before executing commands on it).
+// A directive is serialized with encoding/json, so custom implementations have to include the type in
+// their JSON, usually as a `json:"type"` field returned by DirectiveType.
+type Directive interface {
+	DirectiveType() string
 }
 
 // EchoDirective includes information about intents and slots that should be confirmed or elicted from the user.
@@ -400,3 +1180,26 @@ type EchoDirective struct {
 	SlotToElicit    string      `json:"slotToElicit,omitempty"`
 	IntentToConfirm string      `json:"intentToConfirm,omitempty"`
 }
+
+// DirectiveType returns the type of the dialog directive.
+func (d *EchoDirective) DirectiveType() string {
+	return string(d.Type)
+}
+
+// DialogDelegateRequestDirective passes the control of the dialog between the skill and Alexa Conversations.
+type DialogDelegateRequestDirective struct {
+	Type           dialog.Type          `json:"type"`
+	Target         string               `json:"target"`
+	Period         DialogDelegatePeriod `json:"period"`
+	UpdatedRequest json.RawMessage      `json:"updatedRequest,omitempty"`
+}
+
+// DialogDelegatePeriod describes how long the target of a DialogDelegateRequestDirective keeps control.
+type DialogDelegatePeriod struct {
+	Until string `json:"until"`
+}
+
+// DirectiveType returns the type of the delegate request directive.
+func (d *DialogDelegateRequestDirective) DirectiveType() string {
+	return string(d.Type)
+}
@@ -0,0 +1,985 @@
+package skillserver
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikeflynn/go-alexa/skillserver/dialog"
+)
+
+// Returns the types of the directives in the serialized response, in the order they were serialized.
+func serializedDirectiveTypes(t *testing.T, resp *EchoResponse) []string {
+	t.Helper()
+
+	data, err := resp.String()
+	if err != nil {
+		t.Fatalf("String() error = %v", err)
+	}
+
+	var body struct {
+		Response struct {
+			Directives []struct {
+				Type string `json:"type"`
+			} `json:"directives"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("could not decode response %s: %v", data, err)
+	}
+
+	var types []string
+	for _, d := range body.Response.Directives {
+		types = append(types, d.Type)
+	}
+
+	return types
+}
+
+// Checks that the directives of the serialized response are equal to the JSON array, ignoring formatting.
+func checkDirectivesJSON(t *testing.T, resp *EchoResponse, want string) {
+	t.Helper()
+
+	data, err := resp.String()
+	if err != nil {
+		t.Fatalf("String() error = %v", err)
+	}
+
+	var body struct {
+		Response struct {
+			Directives interface{} `json:"directives"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("could not decode response %s: %v", data, err)
+	}
+
+	var wantDirectives interface{}
+	if err := json.Unmarshal([]byte(want), &wantDirectives); err != nil {
+		t.Fatalf("could not decode expected directives %s: %v", want, err)
+	}
+
+	if !reflect.DeepEqual(body.Response.Directives, wantDirectives) {
+		got, _ := json.Marshal(body.Response.Directives)
+		t.Errorf("directives = %s, want %s", got, want)
+	}
+}
+
+func TestDirectivesKeepInsertionOrder(t *testing.T) {
+	resp := NewEchoResponse().
+		AddAPLRenderDocumentDirective("doc", json.RawMessage(`{}`), nil).
+		AddAudioPlayerPlayDirective(PlayReplaceAll, AudioStream{URL: "https://example.com/a.mp3", Token: "a"})
+	if err := resp.AddAPLExecuteCommandsDirective("doc", SpeakItemCommand("text")); err != nil {
+		t.Fatalf("AddAPLExecuteCommandsDirective() error = %v", err)
+	}
+
+	want := []string{APLRenderDocument, AudioPlayerPlay, APLExecuteCommands}
+	if got := serializedDirectiveTypes(t, resp); !reflect.DeepEqual(got, want) {
+		t.Errorf("directives = %v, want %v", got, want)
+	}
+}
+
+func TestInsertDirectiveAt(t *testing.T) {
+	tests := []struct {
+		name  string
+		index int
+		want  []string
+	}{
+		{"front", 0, []string{ConnectionsSendRequest, APLRenderDocument, APLExecuteCommands}},
+		{"middle", 1, []string{APLRenderDocument, ConnectionsSendRequest, APLExecuteCommands}},
+		{"negative", -1, []string{ConnectionsSendRequest, APLRenderDocument, APLExecuteCommands}},
+		{"past the end", 5, []string{APLRenderDocument, APLExecuteCommands, ConnectionsSendRequest}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := NewEchoResponse().AddAPLRenderDocumentDirective("doc", json.RawMessage(`{}`), nil)
+			if err := resp.AddAPLExecuteCommandsDirective("doc", SpeakItemCommand("text")); err != nil {
+				t.Fatalf("AddAPLExecuteCommandsDirective() error = %v", err)
+			}
+
+			resp.InsertDirectiveAt(tt.index, &ConnectionsDirective{Type: ConnectionsSendRequest, Name: "AskFor"})
+
+			if got := serializedDirectiveTypes(t, resp); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("directives = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type customDirective struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func (d *customDirective) DirectiveType() string {
+	return d.Type
+}
+
+func TestAddDirectiveSerializesCustomDirective(t *testing.T) {
+	resp := NewEchoResponse().AddDirective(&customDirective{Type: "Custom.Directive", Value: "x"})
+
+	data, err := resp.String()
+	if err != nil {
+		t.Fatalf("String() error = %v", err)
+	}
+
+	var body struct {
+		Response struct {
+			Directives []customDirective `json:"directives"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("could not decode response %s: %v", data, err)
+	}
+
+	want := []customDirective{{Type: "Custom.Directive", Value: "x"}}
+	if !reflect.DeepEqual(body.Response.Directives, want) {
+		t.Errorf("directives = %+v, want %+v", body.Response.Directives, want)
+	}
+}
+
+func TestNilDirectivesAreIgnored(t *testing.T) {
+	resp := NewEchoResponse().
+		AddDirective(nil).
+		InsertDirectiveAt(0, nil).
+		AddDirective((*EchoDirective)(nil)).
+		AddDirective(&customDirective{Type: "Custom.Directive"}).
+		InsertDirectiveAt(0, nil).
+		InsertDirectiveAt(0, (*customDirective)(nil)).
+		SimpleCard("title", "content").
+		RemoveDirective("Other.Directive")
+
+	if got := len(resp.Response.Directives); got != 1 {
+		t.Fatalf("len(Directives) = %d, want 1", got)
+	}
+
+	if got := serializedDirectiveTypes(t, resp); !reflect.DeepEqual(got, []string{"Custom.Directive"}) {
+		t.Errorf("directives = %v, want [Custom.Directive]", got)
+	}
+}
+
+func TestIsSimulator(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"simulator without device", `{"context":{"System":{}},"request":{"type":"LaunchRequest"}}`, true},
+		{"simulator without device ID", `{"context":{"System":{"device":{"supportedInterfaces":{}}}},"request":{"type":"LaunchRequest"}}`, true},
+		{"device without interfaces", `{"context":{"System":{"device":{"deviceId":"amzn1.ask.device.1","supportedInterfaces":{}}}},"request":{"type":"LaunchRequest"}}`, false},
+		{"device with interfaces", `{"context":{"System":{"device":{"deviceId":"amzn1.ask.device.1","supportedInterfaces":{"AudioPlayer":{}}}}},"request":{"type":"LaunchRequest"}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := ParseEchoRequest(strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("ParseEchoRequest() error = %v", err)
+			}
+
+			if got := req.IsSimulator(); got != tt.want {
+				t.Errorf("IsSimulator() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialogAPIInvokedRequest(t *testing.T) {
+	req, err := ParseEchoRequest(strings.NewReader(`{
+		"request": {
+			"type": "Dialog.API.Invoked",
+			"apiRequest": {"name": "GetWeather", "arguments": {"city": "Berlin", "days": 3}}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseEchoRequest() error = %v", err)
+	}
+
+	if got := req.GetAPIRequestName(); got != "GetWeather" {
+		t.Errorf("GetAPIRequestName() = %q, want %q", got, "GetWeather")
+	}
+
+	args := req.GetAPIRequestArguments()
+	if got := string(args["city"]); got != `"Berlin"` {
+		t.Errorf("argument city = %s, want %q", got, "Berlin")
+	}
+	if got := string(args["days"]); got != "3" {
+		t.Errorf("argument days = %s, want 3", got)
+	}
+}
+
+func TestDialogAPIResponseSerialization(t *testing.T) {
+	data, err := NewEchoResponse().DialogAPIResponse(json.RawMessage(`{"temperature":21}`)).String()
+	if err != nil {
+		t.Fatalf("String() error = %v", err)
+	}
+
+	var body struct {
+		Response struct {
+			APIResponse json.RawMessage `json:"apiResponse"`
+			Directives  []interface{}   `json:"directives"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("could not decode response %s: %v", data, err)
+	}
+
+	if got := string(body.Response.APIResponse); got != `{"temperature":21}` {
+		t.Errorf("apiResponse = %s, want %s", got, `{"temperature":21}`)
+	}
+	if len(body.Response.Directives) != 0 {
+		t.Errorf("directives = %v, want none", body.Response.Directives)
+	}
+}
+
+// Returns the session attributes of the serialized response, nil if the field is omitted.
+func serializedSessionAttributes(t *testing.T, resp *EchoResponse) map[string]interface{} {
+	t.Helper()
+
+	data, err := resp.String()
+	if err != nil {
+		t.Fatalf("String() error = %v", err)
+	}
+
+	var body struct {
+		SessionAttributes map[string]interface{} `json:"sessionAttributes"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("could not decode response %s: %v", data, err)
+	}
+
+	return body.SessionAttributes
+}
+
+func TestDeleteSessionAttribute(t *testing.T) {
+	resp := NewEchoResponse()
+	resp.SessionAttributes["keep"] = "a"
+	resp.SessionAttributes["drop"] = "b"
+
+	resp.DeleteSessionAttribute("drop").DeleteSessionAttribute("missing")
+
+	got := serializedSessionAttributes(t, resp)
+	if want := map[string]interface{}{"keep": "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sessionAttributes = %v, want %v", got, want)
+	}
+}
+
+func TestClearSessionAttributes(t *testing.T) {
+	resp := NewEchoResponse()
+	resp.SessionAttributes["a"] = 1
+	resp.SessionAttributes["b"] = 2
+
+	if got := serializedSessionAttributes(t, resp.ClearSessionAttributes()); got != nil {
+		t.Errorf("sessionAttributes = %v, want the field omitted", got)
+	}
+}
+
+// Parses a request of the type with the top level JSON fields, e.g. `"context":{...}`.
+func parseTestRequest(t *testing.T, requestType, fields string) *EchoRequest {
+	t.Helper()
+
+	body := `{"request":{"type":"` + requestType + `"}`
+	if fields != "" {
+		body += "," + fields
+	}
+	req, err := ParseEchoRequest(strings.NewReader(body + "}"))
+	if err != nil {
+		t.Fatalf("ParseEchoRequest() error = %v", err)
+	}
+
+	return req
+}
+
+func TestGetConsentToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		fields    string
+		wantToken string
+		wantOK    bool
+	}{
+		{"no permissions", `"context":{"System":{"user":{"userId":"u"}}}`, "", false},
+		{"empty token", `"context":{"System":{"user":{"permissions":{"consentToken":""}}}}`, "", false},
+		{"token in context", `"context":{"System":{"user":{"permissions":{"consentToken":"context-token"}}}}`, "context-token", true},
+		{"token in session", `"session":{"user":{"permissions":{"consentToken":"session-token"}}}`, "session-token", true},
+		{"context preferred", `"session":{"user":{"permissions":{"consentToken":"session-token"}}},"context":{"System":{"user":{"permissions":{"consentToken":"context-token"}}}}`, "context-token", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := parseTestRequest(t, "LaunchRequest", tt.fields)
+
+			token, ok := req.GetConsentToken()
+			if token != tt.wantToken || ok != tt.wantOK {
+				t.Errorf("GetConsentToken() = %q, %v, want %q, %v", token, ok, tt.wantToken, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRequiresMoreSlots(t *testing.T) {
+	req := parseTestRequest(t, "IntentRequest", "")
+	req.Request.Intent = EchoIntent{
+		Name: "BookTrip",
+		Slots: map[string]EchoSlot{
+			"city":      {Name: "city", Value: "Berlin", ConfirmationStatus: ConfConfirmed},
+			"date":      {Name: "date"},
+			"travelers": {Name: "travelers", Value: "2", ConfirmationStatus: ConfDenied},
+			"class":     {Name: "class", Value: "economy", ConfirmationStatus: ConfNone},
+		},
+	}
+
+	got := req.RequiresMoreSlots([]string{"city", "date", "travelers", "class", "returnDate"})
+	if want := []string{"date", "travelers", "returnDate"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RequiresMoreSlots() = %v, want %v", got, want)
+	}
+
+	if got := req.RequiresMoreSlots([]string{"city", "class"}); len(got) != 0 {
+		t.Errorf("RequiresMoreSlots() of filled slots = %v, want none", got)
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	// Counted in characters including the tags, not in bytes.
+	const ssml = "<speak>ö words</speak>"
+	limits := ResponseLimits{MaxSpeechLength: len([]rune(ssml))}
+
+	tests := []struct {
+		name   string
+		resp   *EchoResponse
+		limits ResponseLimits
+		want   error
+	}{
+		{"SSML within the limit", NewEchoResponse().OutputSpeechSSML(ssml), limits, nil},
+		{"SSML one character too long", NewEchoResponse().OutputSpeechSSML(ssml + " "), limits, ErrSpeechTooLong},
+		{"reprompt too long", NewEchoResponse().OutputSpeech("short").RepromptSSML(ssml + " "), limits, ErrSpeechTooLong},
+		{"response too large", NewEchoResponse().OutputSpeech("short"), ResponseLimits{MaxResponseSize: 10}, ErrResponseTooLarge},
+		{"no limits", NewEchoResponse().OutputSpeechSSML(strings.Repeat(ssml, 1000)), ResponseLimits{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.resp.CheckLimits(tt.limits)
+			if tt.want == nil && err != nil {
+				t.Errorf("CheckLimits() error = %v, want nil", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("CheckLimits() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetUnitID(t *testing.T) {
+	a4b := parseTestRequest(t, "LaunchRequest", `"context":{"System":{"unit":{"unitId":"amzn1.ask.unit.1","persistentUnitId":"amzn1.alexa.unit.did.1"}}}`)
+	if id, ok := a4b.GetUnitID(); id != "amzn1.ask.unit.1" || !ok {
+		t.Errorf("GetUnitID() = %q, %v, want %q, true", id, ok, "amzn1.ask.unit.1")
+	}
+	if id, ok := a4b.GetPersistentUnitID(); id != "amzn1.alexa.unit.did.1" || !ok {
+		t.Errorf("GetPersistentUnitID() = %q, %v, want %q, true", id, ok, "amzn1.alexa.unit.did.1")
+	}
+
+	consumer := parseTestRequest(t, "LaunchRequest", `"context":{"System":{"device":{"deviceId":"d"}}}`)
+	if id, ok := consumer.GetUnitID(); id != "" || ok {
+		t.Errorf("GetUnitID() without unit = %q, %v, want false", id, ok)
+	}
+	if id, ok := consumer.GetPersistentUnitID(); id != "" || ok {
+		t.Errorf("GetPersistentUnitID() without unit = %q, %v, want false", id, ok)
+	}
+}
+
+func TestGetContextObject(t *testing.T) {
+	req := parseTestRequest(t, "LaunchRequest", `"context":{"System":{},"Geolocation":{"timestamp":"2019-02-03T12:00:00Z","newField":{"a":1}}}`)
+
+	raw, ok := req.GetContextObject("Geolocation")
+	if !ok {
+		t.Fatal("GetContextObject(Geolocation) = false, want true")
+	}
+
+	// Fields that aren't modelled are kept in the raw JSON.
+	var geo struct {
+		Timestamp string `json:"timestamp"`
+		NewField  struct {
+			A int `json:"a"`
+		} `json:"newField"`
+	}
+	if err := json.Unmarshal(raw, &geo); err != nil {
+		t.Fatalf("could not decode %s: %v", raw, err)
+	}
+	if geo.Timestamp != "2019-02-03T12:00:00Z" || geo.NewField.A != 1 {
+		t.Errorf("Geolocation = %+v, want the raw context object", geo)
+	}
+
+	if raw, ok := req.GetContextObject("Viewport"); ok || raw != nil {
+		t.Errorf("GetContextObject(Viewport) = %s, %v, want nil, false", raw, ok)
+	}
+}
+
+func TestStringIsDeterministic(t *testing.T) {
+	build := func() *EchoResponse {
+		resp := NewEchoResponse().OutputSpeech("Hello").SimpleCard("title", "content")
+		for i := 0; i < 20; i++ {
+			resp.SessionAttributes[string(rune('a'+i))] = map[string]int{"x": i, "y": i, "z": i}
+		}
+		intent := &EchoIntent{Name: "Order", Slots: map[string]EchoSlot{}}
+		for i := 0; i < 20; i++ {
+			name := string(rune('a' + i))
+			intent.Slots[name] = EchoSlot{Name: name, Value: name}
+			resp.SetRaw("custom"+name, json.RawMessage(`{"b": 1, "a": 2}`))
+		}
+		return resp.RespondToIntent(dialog.Delegate, intent, nil)
+	}
+
+	first, err := build().String()
+	if err != nil {
+		t.Fatalf("String() error = %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		again, err := build().String()
+		if err != nil {
+			t.Fatalf("String() error = %v", err)
+		}
+		if string(again) != string(first) {
+			t.Fatalf("String() = %s, want the same bytes as %s", again, first)
+		}
+	}
+}
+
+// Returns the raw top level fields of the serialized response object.
+func serializedResponseFields(t *testing.T, resp *EchoResponse) map[string]json.RawMessage {
+	t.Helper()
+
+	data, err := resp.String()
+	if err != nil {
+		t.Fatalf("String() error = %v", err)
+	}
+
+	var body struct {
+		Response map[string]json.RawMessage `json:"response"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("could not decode response %s: %v", data, err)
+	}
+
+	return body.Response
+}
+
+func TestKeepSessionOpenSilently(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *EchoResponse
+		want string // Empty if the flag has to be absent.
+	}{
+		{"default", NewEchoResponse(), "true"},
+		{"kept open", NewEchoResponse().EndSession(false), "false"},
+		{"kept open silently", NewEchoResponse().KeepSessionOpenSilently(), ""},
+		{"kept open silently after EndSession", NewEchoResponse().EndSession(true).KeepSessionOpenSilently(), ""},
+		{"kept open silently with a directive", NewEchoResponse().AddAPLRenderDocumentDirective("doc", json.RawMessage(`{}`), nil).KeepSessionOpenSilently(), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flag, ok := serializedResponseFields(t, tt.resp)["shouldEndSession"]
+			if tt.want == "" && ok {
+				t.Errorf("shouldEndSession = %s, want the field absent", flag)
+			}
+			if tt.want != "" && string(flag) != tt.want {
+				t.Errorf("shouldEndSession = %s, want %s", flag, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstResolvedValue(t *testing.T) {
+	req, err := ParseEchoRequest(strings.NewReader(`{"request":{"type":"IntentRequest","intent":{"name":"Order","slots":{
+		"drink": {"name": "drink", "value": "coke", "resolutions": {"resolutionsPerAuthority": [
+			{"authority": "amzn1.er-authority.echo-sdk.skill.Drink", "status": {"code": "ER_SUCCESS_NO_MATCH"}},
+			{"authority": "amzn1.er-authority.echo-sdk.skill.Soda", "status": {"code": "ER_SUCCESS_MATCH"}, "values": [
+				{"value": {"name": "Coca-Cola", "id": "COLA"}},
+				{"value": {"name": "Cola Zero", "id": "ZERO"}}
+			]}
+		]}},
+		"size": {"name": "size", "value": "huge", "resolutions": {"resolutionsPerAuthority": [
+			{"authority": "amzn1.er-authority.echo-sdk.skill.Size", "status": {"code": "ER_SUCCESS_NO_MATCH"}}
+		]}},
+		"note": {"name": "note", "value": "no ice"}
+	}}}}`))
+	if err != nil {
+		t.Fatalf("ParseEchoRequest() error = %v", err)
+	}
+
+	tests := []struct {
+		slot      string
+		wantValue string
+		wantID    string
+		wantOK    bool
+	}{
+		{"drink", "Coca-Cola", "COLA", true},
+		{"size", "", "", false},
+		{"note", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.slot, func(t *testing.T) {
+			slot, ok := req.GetSlot(tt.slot)
+			if !ok {
+				t.Fatalf("GetSlot(%q) = false, want true", tt.slot)
+			}
+
+			value, id, ok := slot.FirstResolvedValue()
+			if value != tt.wantValue || id != tt.wantID || ok != tt.wantOK {
+				t.Errorf("FirstResolvedValue() = %q, %q, %v, want %q, %q, %v", value, id, ok, tt.wantValue, tt.wantID, tt.wantOK)
+			}
+		})
+	}
+
+	if _, ok := req.GetSlot("missing"); ok {
+		t.Error("GetSlot(missing) = true, want false")
+	}
+}
+
+func TestGetIntentJSON(t *testing.T) {
+	req, err := ParseEchoRequest(strings.NewReader(`{"request":{"type":"IntentRequest","intent":{
+		"name": "PlanRoute",
+		"slots": {"stops": {"name": "stops", "slotValue": {"type": "List", "values": [
+			{"type": "Simple", "value": "Berlin"},
+			{"type": "Simple", "value": "Hamburg"}
+		]}}}
+	}}}`))
+	if err != nil {
+		t.Fatalf("ParseEchoRequest() error = %v", err)
+	}
+
+	raw, ok := req.GetIntentJSON()
+	if !ok {
+		t.Fatal("GetIntentJSON() = false, want true")
+	}
+
+	var intent struct {
+		Name  string `json:"name"`
+		Slots map[string]struct {
+			SlotValue struct {
+				Type   string `json:"type"`
+				Values []struct {
+					Value string `json:"value"`
+				} `json:"values"`
+			} `json:"slotValue"`
+		} `json:"slots"`
+	}
+	if err := json.Unmarshal(raw, &intent); err != nil {
+		t.Fatalf("could not decode intent %s: %v", raw, err)
+	}
+
+	stops := intent.Slots["stops"].SlotValue
+	if intent.Name != "PlanRoute" || stops.Type != "List" || len(stops.Values) != 2 || stops.Values[1].Value != "Hamburg" {
+		t.Errorf("intent = %+v, want the list slot of the request", intent)
+	}
+
+	if raw, ok := parseTestRequest(t, "LaunchRequest", "").GetIntentJSON(); ok {
+		t.Errorf("GetIntentJSON() of a launch request = %s, true, want false", raw)
+	}
+}
+
+func TestGetUserID(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		want   string
+	}{
+		{"session only", `"session":{"user":{"userId":"session-user"}}`, "session-user"},
+		{"context only", `"context":{"System":{"user":{"userId":"context-user"}}}`, "context-user"},
+		{"context preferred", `"session":{"user":{"userId":"session-user"}},"context":{"System":{"user":{"userId":"context-user"}}}`, "context-user"},
+		{"none", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseTestRequest(t, "LaunchRequest", tt.fields).GetUserID(); got != tt.want {
+				t.Errorf("GetUserID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClearDirectives(t *testing.T) {
+	resp := NewEchoResponse().
+		AddAPLRenderDocumentDirective("doc", json.RawMessage(`{}`), nil).
+		AddAudioPlayerPlayDirective(PlayReplaceAll, AudioStream{URL: "https://example.com/a.mp3", Token: "a"}).
+		ClearDirectives()
+
+	if _, ok := serializedResponseFields(t, resp)["directives"]; ok {
+		t.Errorf("directives = %v, want the field omitted", resp.Response.Directives)
+	}
+
+	// Directives can be added again afterwards.
+	resp.AddDirective(&customDirective{Type: "Custom.Directive"})
+	if got := serializedDirectiveTypes(t, resp); !reflect.DeepEqual(got, []string{"Custom.Directive"}) {
+		t.Errorf("directives = %v, want [Custom.Directive]", got)
+	}
+}
+
+func TestRemoveDirective(t *testing.T) {
+	resp := NewEchoResponse().
+		AddAPLRenderDocumentDirective("doc", json.RawMessage(`{}`), nil).
+		AddAudioPlayerPlayDirective(PlayReplaceAll, AudioStream{URL: "https://example.com/a.mp3", Token: "a"}).
+		AddAPLRenderDocumentDirective("other", json.RawMessage(`{}`), nil).
+		AddDirective(&customDirective{Type: "Custom.Directive"})
+
+	resp.RemoveDirective(APLRenderDocument)
+	if got, want := serializedDirectiveTypes(t, resp), []string{AudioPlayerPlay, "Custom.Directive"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("directives = %v, want %v", got, want)
+	}
+
+	resp.RemoveDirective(AudioPlayerPlay).RemoveDirective("Custom.Directive")
+	if _, ok := serializedResponseFields(t, resp)["directives"]; ok {
+		t.Errorf("directives = %v, want the field omitted", resp.Response.Directives)
+	}
+}
+
+func TestGetRawSlotValue(t *testing.T) {
+	req, err := ParseEchoRequest(strings.NewReader(`{"request":{"type":"IntentRequest","intent":{"name":"Search","slots":{
+		"query": {"name": "query", "value": "how tall is the eiffel tower"},
+		"empty": {"name": "empty"}
+	}}}}`))
+	if err != nil {
+		t.Fatalf("ParseEchoRequest() error = %v", err)
+	}
+
+	if value, ok := req.GetRawSlotValue("query"); value != "how tall is the eiffel tower" || !ok {
+		t.Errorf("GetRawSlotValue(query) = %q, %v, want the spoken query", value, ok)
+	}
+	for _, name := range []string{"empty", "missing"} {
+		if value, ok := req.GetRawSlotValue(name); value != "" || ok {
+			t.Errorf("GetRawSlotValue(%s) = %q, %v, want false", name, value, ok)
+		}
+	}
+}
+
+func TestOutputSpeechRich(t *testing.T) {
+	resp := NewEchoResponse().OutputSpeechRich("Hello there", "<speak>Hello <break time=\"1s\"/> there</speak>")
+
+	var speech struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+		SSML string `json:"ssml"`
+	}
+	raw := serializedResponseFields(t, resp)["outputSpeech"]
+	if err := json.Unmarshal(raw, &speech); err != nil {
+		t.Fatalf("could not decode outputSpeech %s: %v", raw, err)
+	}
+
+	// Only the SSML is sent, the plain text is kept for logging.
+	if speech.Type != "SSML" || speech.SSML != `<speak>Hello <break time="1s"/> there</speak>` || speech.Text != "" {
+		t.Errorf("outputSpeech = %s, want only the SSML", raw)
+	}
+	if got := resp.SpeechText(); got != "Hello there" {
+		t.Errorf("SpeechText() = %q, want %q", got, "Hello there")
+	}
+
+	// Setting other speech replaces the plain text.
+	if got := resp.OutputSpeechSSML("<speak>Bye</speak>").SpeechText(); got != "" {
+		t.Errorf("SpeechText() after OutputSpeechSSML = %q, want none", got)
+	}
+	if got := resp.OutputSpeech("Bye").SpeechText(); got != "Bye" {
+		t.Errorf("SpeechText() after OutputSpeech = %q, want %q", got, "Bye")
+	}
+}
+
+func TestIsDialogComplete(t *testing.T) {
+	req, err := ParseEchoRequest(strings.NewReader(`{"request":{
+		"type": "IntentRequest",
+		"dialogState": "COMPLETED",
+		"intent": {"name": "BookTrip", "confirmationStatus": "CONFIRMED", "slots": {
+			"city": {"name": "city", "value": "Berlin", "confirmationStatus": "CONFIRMED"},
+			"date": {"name": "date", "value": "2019-05-01", "confirmationStatus": "NONE"}
+		}}
+	}}`))
+	if err != nil {
+		t.Fatalf("ParseEchoRequest() error = %v", err)
+	}
+
+	if got := req.GetDialogState(); got != dialog.Completed {
+		t.Errorf("GetDialogState() = %q, want %q", got, dialog.Completed)
+	}
+	if !req.IsDialogComplete() {
+		t.Error("IsDialogComplete() = false, want true")
+	}
+
+	intent := req.GetIntent()
+	if intent.Name != "BookTrip" || intent.Slots["city"].Value != "Berlin" || intent.Slots["date"].Value != "2019-05-01" {
+		t.Errorf("GetIntent() = %+v, want the slots collected by the dialog", intent)
+	}
+
+	for _, state := range []string{dialog.Started, dialog.InProgress, ""} {
+		req.Request.DialogState = state
+		if req.IsDialogComplete() {
+			t.Errorf("IsDialogComplete() in state %q = true, want false", state)
+		}
+	}
+}
+
+func TestSetRaw(t *testing.T) {
+	resp := NewEchoResponse().
+		OutputSpeech("Hello").
+		SetRaw("experimentation", json.RawMessage(`{"treatment":"T1"}`)).
+		SetRaw("removed", json.RawMessage(`true`)).
+		SetRaw("removed", nil)
+
+	fields := serializedResponseFields(t, resp)
+	if got := string(fields["experimentation"]); got != `{"treatment":"T1"}` {
+		t.Errorf("experimentation = %s, want the custom field", got)
+	}
+	if _, ok := fields["removed"]; ok {
+		t.Error("removed field is serialized, want it absent")
+	}
+	if _, ok := fields["outputSpeech"]; !ok {
+		t.Error("outputSpeech is missing, want the modelled fields kept")
+	}
+
+	// A raw field replaces the modelled field of the same name.
+	resp.SetRaw("shouldEndSession", json.RawMessage(`false`))
+	if got := string(serializedResponseFields(t, resp)["shouldEndSession"]); got != "false" {
+		t.Errorf("shouldEndSession = %s, want the raw value false", got)
+	}
+}
+
+func TestAddDialogDelegateRequestDirective(t *testing.T) {
+	resp := NewEchoResponse().AddDialogDelegateRequestDirective("AMAZON.Conversations", "EXPLICIT_RETURN",
+		json.RawMessage(`{"type":"Dialog.InputRequest","input":{"name":"OrderPizza"}}`))
+
+	checkDirectivesJSON(t, resp, `[{
+		"type": "Dialog.DelegateRequest",
+		"target": "AMAZON.Conversations",
+		"period": {"until": "EXPLICIT_RETURN"},
+		"updatedRequest": {"type": "Dialog.InputRequest", "input": {"name": "OrderPizza"}}
+	}]`)
+
+	// The session is kept open for the target to continue the dialog.
+	if got := string(serializedResponseFields(t, resp)["shouldEndSession"]); got != "false" {
+		t.Errorf("shouldEndSession = %s, want false", got)
+	}
+}
+
+func TestSpeechAndRepromptTypes(t *testing.T) {
+	resp := NewEchoResponse().OutputSpeechSSML("<speak>Which city?</speak>").Reprompt("Please name a city.")
+
+	if speech := resp.GetOutputSpeech(); speech == nil || speech.Type != "SSML" || speech.SSML != "<speak>Which city?</speak>" {
+		t.Errorf("GetOutputSpeech() = %+v, want SSML", speech)
+	}
+	if reprompt := resp.GetReprompt(); reprompt == nil || reprompt.Type != "PlainText" || reprompt.Text != "Please name a city." {
+		t.Errorf("GetReprompt() = %+v, want plain text", reprompt)
+	}
+
+	resp.OutputSpeech("Which city?").RepromptSSML("<speak>Please name a city.</speak>")
+	if speech := resp.GetOutputSpeech(); speech.Type != "PlainText" || speech.Text != "Which city?" {
+		t.Errorf("GetOutputSpeech() = %+v, want plain text", speech)
+	}
+	if reprompt := resp.GetReprompt(); reprompt.Type != "SSML" || reprompt.SSML != "<speak>Please name a city.</speak>" {
+		t.Errorf("GetReprompt() = %+v, want SSML", reprompt)
+	}
+
+	empty := NewEchoResponse()
+	if speech, reprompt := empty.GetOutputSpeech(), empty.GetReprompt(); speech != nil || reprompt != nil {
+		t.Errorf("GetOutputSpeech(), GetReprompt() = %+v, %+v, want nil", speech, reprompt)
+	}
+}
+
+func TestGetSlots(t *testing.T) {
+	req, err := ParseEchoRequest(strings.NewReader(`{"request":{"type":"IntentRequest","intent":{"name":"Order","slots":{
+		"drink": {"name": "drink", "value": "coke", "resolutions": {"resolutionsPerAuthority": [
+			{"authority": "drinks", "status": {"code": "ER_SUCCESS_MATCH"}, "values": [{"value": {"name": "Coca-Cola", "id": "COLA"}}]}
+		]}},
+		"size": {"name": "size", "value": "large", "confirmationStatus": "CONFIRMED"},
+		"note": {"name": "note"}
+	}}}}`))
+	if err != nil {
+		t.Fatalf("ParseEchoRequest() error = %v", err)
+	}
+
+	slots := req.GetSlots()
+	if len(slots) != 3 {
+		t.Fatalf("GetSlots() = %+v, want 3 slots", slots)
+	}
+	drink := slots["drink"]
+	if value, id, _ := drink.FirstResolvedValue(); value != "Coca-Cola" || id != "COLA" {
+		t.Errorf("drink resolves to %q, %q, want the resolution of the request", value, id)
+	}
+	if s := slots["size"]; s.Value != "large" || s.ConfirmationStatus != ConfConfirmed {
+		t.Errorf("size = %+v, want the confirmed value large", s)
+	}
+	if s, ok := slots["note"]; !ok || s.Value != "" {
+		t.Errorf("note = %+v, %v, want the empty slot", s, ok)
+	}
+
+	// The returned map is a copy.
+	delete(slots, "drink")
+	if len(req.GetSlots()) != 3 || len(req.AllSlots()) != 3 {
+		t.Error("changing the map returned by GetSlots changed the request")
+	}
+}
+
+func TestParseEchoRequest(t *testing.T) {
+	req, err := ParseEchoRequest(strings.NewReader(testRequestBodyWith("IntentRequest", `"intent": {"name": "HelloIntent"}`)))
+	if err != nil {
+		t.Fatalf("ParseEchoRequest() error = %v", err)
+	}
+
+	if req.Version != "1.0" || req.GetRequestType() != "IntentRequest" || req.GetIntentName() != "HelloIntent" {
+		t.Errorf("request = %+v, want the hello intent", req.Request)
+	}
+	if req.GetSessionID() != "session" || req.Request.RequestID != "request" || req.Locale() != "en-US" {
+		t.Errorf("request = %+v, want the session, ID and locale of the body", req)
+	}
+	if !req.VerifyAppID(testAppID) || !req.VerifyTimestamp() {
+		t.Error("request doesn't verify against the application ID and current time of the body")
+	}
+}
+
+func TestParseEchoRequestErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want error
+	}{
+		{"empty body", " \n", ErrEmptyBody},
+		{"invalid JSON", `{"request":`, ErrInvalidJSON},
+		{"wrong structure", `{"request":"LaunchRequest"}`, ErrInvalidJSON},
+		{"null", "null", ErrNotAlexaRequest},
+		{"not an Alexa request", `{"name":"webhook","payload":{}}`, ErrNotAlexaRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseEchoRequest(strings.NewReader(tt.body)); !errors.Is(err, tt.want) {
+				t.Errorf("ParseEchoRequest() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyTimestamp(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset time.Duration
+		want   bool
+	}{
+		{"now", 0, true},
+		{"10 seconds in the future", 10 * time.Second, true},
+		{"5 minutes in the future", 5 * time.Minute, false},
+		{"10 seconds ago", -10 * time.Second, true},
+		{"5 minutes ago", -5 * time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &EchoRequest{}
+			req.Request.Timestamp = time.Now().Add(tt.offset).UTC().Format("2006-01-02T15:04:05Z")
+			if got := req.VerifyTimestamp(); got != tt.want {
+				t.Errorf("VerifyTimestamp() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStandardCardDropsInsecureImages(t *testing.T) {
+	var resp *EchoResponse
+	logged := captureLog(func() {
+		resp = NewEchoResponse().StandardCard("title", "content", "http://example.com/small.png", "https://example.com/large.png")
+	})
+
+	image := resp.Response.Card.Image
+	if image.SmallImageURL != "" || image.LargeImageURL != "https://example.com/large.png" {
+		t.Errorf("card image = %+v, want only the https image", image)
+	}
+	if !strings.Contains(logged, "Removed small card image") {
+		t.Errorf("log = %q, want a warning about the small image", logged)
+	}
+}
+
+func TestUnconfirmedSlots(t *testing.T) {
+	req, err := ParseEchoRequest(strings.NewReader(`{"request":{"type":"IntentRequest","intent":{"name":"BookIntent","slots":{
+		"city": {"name": "city", "value": "Berlin", "confirmationStatus": "CONFIRMED"},
+		"date": {"name": "date", "value": "2019-02-03", "confirmationStatus": "NONE"},
+		"time": {"name": "time", "value": "noon", "confirmationStatus": "DENIED"},
+		"guests": {"name": "guests", "value": "2"},
+		"room": {"name": "room", "confirmationStatus": "NONE"}
+	}}}}`))
+	if err != nil {
+		t.Fatalf("ParseEchoRequest() error = %v", err)
+	}
+
+	got := req.UnconfirmedSlots([]string{"guests", "city", "date", "time", "room", "missing"})
+	if want := []string{"guests", "date"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("UnconfirmedSlots() = %v, want %v", got, want)
+	}
+}
+
+func TestLastCardWins(t *testing.T) {
+	var resp *EchoResponse
+	logged := captureLog(func() {
+		resp = NewEchoResponse().SimpleCard("first", "replaced").StandardCard("second", "shown", "", "")
+	})
+
+	card := resp.Response.Card
+	if card.Type != "Standard" || card.Title != "second" || card.Content != "shown" {
+		t.Errorf("card = %+v, want the standard card", card)
+	}
+	if !strings.Contains(logged, "Simple card replaced by Standard card") {
+		t.Errorf("log = %q, want a note about the replaced card", logged)
+	}
+}
+
+func TestCardWithAudioPlayerDirectiveIsFlagged(t *testing.T) {
+	logged := captureLog(func() {
+		NewEchoResponse().
+			AddAudioPlayerPlayDirective(PlayReplaceAll, AudioStream{URL: "https://example.com/1.mp3", Token: "track-1"}).
+			SimpleCard("title", "content")
+	})
+
+	if !strings.Contains(logged, "Simple card set alongside AudioPlayer.Play directive") {
+		t.Errorf("log = %q, want a warning about the card", logged)
+	}
+}
+
+func TestDirectivesKeepSessionOpen(t *testing.T) {
+	elicit := func() *EchoResponse {
+		return NewEchoResponse().OutputSpeech("Which city?").
+			RespondToIntent(dialog.ElicitSlot, &EchoIntent{Name: "BookIntent"}, &Slot{Name: "city"})
+	}
+	executeCommands := NewEchoResponse()
+	if err := executeCommands.AddAPLExecuteCommandsDirective("doc", SpeakItemCommand("headline")); err != nil {
+		t.Fatalf("AddAPLExecuteCommandsDirective() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		resp *EchoResponse
+		want string // Empty if the flag has to be absent.
+	}{
+		{"elicit slot", elicit(), "false"},
+		{"elicit slot with explicit end", elicit().EndSession(true), "true"},
+		{"dynamic entities", NewEchoResponse().AddDirective(&customDirective{Type: "Dialog.UpdateDynamicEntities"}), "true"},
+		{"execute commands", executeCommands, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields map[string]json.RawMessage
+			captureLog(func() {
+				fields = serializedResponseFields(t, tt.resp)
+			})
+
+			flag, ok := fields["shouldEndSession"]
+			if tt.want == "" && ok {
+				t.Errorf("shouldEndSession = %s, want the field absent", flag)
+			}
+			if tt.want != "" && string(flag) != tt.want {
+				t.Errorf("shouldEndSession = %s, want %s", flag, tt.want)
+			}
+		})
+	}
+}
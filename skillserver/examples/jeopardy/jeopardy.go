@@ -121,9 +121,7 @@ func EchoJeopardy(w http.ResponseWriter, r *http.Request) {
 
 		echoResp, _ := jeopardyStart(echoReq, session)
 
-		json, _ := echoResp.String()
-		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
-		w.Write(json)
+		alexa.WriteEcho(w, echoResp)
 	} else if echoReq.GetRequestType() == "IntentRequest" {
 		log.Println(echoReq.GetIntentName())
 
@@ -163,9 +161,7 @@ func EchoJeopardy(w http.ResponseWriter, r *http.Request) {
 			echoResp = alexa.NewEchoResponse().OutputSpeech("I'm sorry, I didn't get that. Can you say that again?").EndSession(false)
 		}
 
-		json, _ := echoResp.String()
-		w.Header().Set("Content-Type", "application/json;charset=UTF-8")
-		w.Write(json)
+		alexa.WriteEcho(w, echoResp)
 	} else if echoReq.GetRequestType() == "SessionEndedRequest" {
 		//session.Delete(col)
 	}
@@ -0,0 +1,56 @@
+package skillserver
+
+/**
+ * Details about the Geolocation interface can be found on this page:
+ * https://developer.amazon.com/docs/custom-skills/location-services-for-alexa-skills.html
+ */
+
+// Geolocation contains the location of the end user's device. It is only sent by devices that
+// support location services and after the end user has granted the skill the geolocation permission.
+type Geolocation struct {
+	LocationServices *struct {
+		Access string `json:"access"` // ENABLED or DISABLED
+		Status string `json:"status"` // RUNNING or STOPPED
+	} `json:"locationServices,omitempty"`
+	Timestamp  string `json:"timestamp,omitempty"`
+	Coordinate *struct {
+		LatitudeInDegrees  float64 `json:"latitudeInDegrees"`
+		LongitudeInDegrees float64 `json:"longitudeInDegrees"`
+		AccuracyInMeters   float64 `json:"accuracyInMeters"`
+	} `json:"coordinate,omitempty"`
+	Altitude *struct {
+		AltitudeInMeters float64 `json:"altitudeInMeters"`
+		AccuracyInMeters float64 `json:"accuracyInMeters"`
+	} `json:"altitude,omitempty"`
+	Heading *struct {
+		DirectionInDegrees float64 `json:"directionInDegrees"`
+		AccuracyInDegrees  float64 `json:"accuracyInDegrees,omitempty"`
+	} `json:"heading,omitempty"`
+	Speed *struct {
+		SpeedInMetersPerSecond    float64 `json:"speedInMetersPerSecond"`
+		AccuracyInMetersPerSecond float64 `json:"accuracyInMetersPerSecond,omitempty"`
+	} `json:"speed,omitempty"`
+}
+
+// LocationServicesEnabled is true if location sharing is enabled on the device and the location
+// services are currently running.
+func (g *Geolocation) LocationServicesEnabled() bool {
+	if g.LocationServices == nil {
+		return false
+	}
+
+	return g.LocationServices.Access == "ENABLED" && g.LocationServices.Status == "RUNNING"
+}
+
+// GetGeolocation returns the location of the device the request was sent from. False is returned if
+// the request contains no coordinates, either because the device doesn't support location services, the
+// skill has not been granted the permission, or location services are disabled on the device. In the
+// latter case the returned Geolocation is not nil and can be inspected with `LocationServicesEnabled`.
+func (r *EchoRequest) GetGeolocation() (*Geolocation, bool) {
+	geo := r.Context.Geolocation
+	if geo == nil || geo.Coordinate == nil {
+		return geo, false
+	}
+
+	return geo, true
+}
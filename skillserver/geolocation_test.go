@@ -0,0 +1,61 @@
+package skillserver
+
+import (
+	"testing"
+)
+
+func TestGetGeolocation(t *testing.T) {
+	req := parseTestRequest(t, "LaunchRequest", `"context":{"Geolocation":{
+		"locationServices": {"access": "ENABLED", "status": "RUNNING"},
+		"timestamp": "2019-02-03T12:00:00Z",
+		"coordinate": {"latitudeInDegrees": 52.52, "longitudeInDegrees": 13.405, "accuracyInMeters": 20},
+		"altitude": {"altitudeInMeters": 34, "accuracyInMeters": 5},
+		"speed": {"speedInMetersPerSecond": 1.5}
+	}}`)
+
+	geo, ok := req.GetGeolocation()
+	if !ok {
+		t.Fatal("GetGeolocation() = false, want true")
+	}
+	if c := geo.Coordinate; c.LatitudeInDegrees != 52.52 || c.LongitudeInDegrees != 13.405 || c.AccuracyInMeters != 20 {
+		t.Errorf("Coordinate = %+v, want the coordinates of the request", *c)
+	}
+	if geo.Altitude == nil || geo.Altitude.AltitudeInMeters != 34 {
+		t.Errorf("Altitude = %+v, want 34 meters", geo.Altitude)
+	}
+	if geo.Speed == nil || geo.Speed.SpeedInMetersPerSecond != 1.5 {
+		t.Errorf("Speed = %+v, want 1.5 meters per second", geo.Speed)
+	}
+	if geo.Heading != nil {
+		t.Errorf("Heading = %+v, want nil", geo.Heading)
+	}
+	if !geo.LocationServicesEnabled() {
+		t.Error("LocationServicesEnabled() = false, want true")
+	}
+}
+
+func TestGetGeolocationWithoutCoordinates(t *testing.T) {
+	tests := []struct {
+		name        string
+		fields      string
+		wantContext bool
+	}{
+		{"no geolocation", `"context":{"System":{}}`, false},
+		{"location services disabled", `"context":{"Geolocation":{"locationServices":{"access":"DISABLED","status":"STOPPED"}}}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			geo, ok := parseTestRequest(t, "LaunchRequest", tt.fields).GetGeolocation()
+			if ok {
+				t.Error("GetGeolocation() = true, want false")
+			}
+			if (geo != nil) != tt.wantContext {
+				t.Fatalf("GetGeolocation() = %+v, want a geolocation: %v", geo, tt.wantContext)
+			}
+			if geo != nil && geo.LocationServicesEnabled() {
+				t.Error("LocationServicesEnabled() = true, want false")
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package skillserver
+
+import (
+	"encoding/json"
+)
+
+/**
+ * Details about the Alexa Web API for Games can be found on this page:
+ * https://developer.amazon.com/docs/alexa/web-api-for-games/alexa-presentation-html-interface.html
+ */
+
+// Directive types of the Alexa.Presentation.HTML interface.
+const (
+	HTMLStart         = "Alexa.Presentation.HTML.Start"
+	HTMLHandleMessage = "Alexa.Presentation.HTML.HandleMessage"
+)
+
+// HTMLRequest describes where the device loads the web application from. The URI must use https.
+type HTMLRequest struct {
+	URI     string            `json:"uri"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// HTMLConfig configures the web application session, the timeout ends it after the given seconds of inactivity.
+type HTMLConfig struct {
+	TimeoutInSeconds int `json:"timeoutInSeconds,omitempty"`
+}
+
+// HTMLDirective starts a web application on the device or sends a message to the running one.
+type HTMLDirective struct {
+	Type          string          `json:"type"`
+	Data          json.RawMessage `json:"data,omitempty"`
+	Request       *HTMLRequest    `json:"request,omitempty"`
+	Configuration *HTMLConfig     `json:"configuration,omitempty"`
+	Message       json.RawMessage `json:"message,omitempty"`
+}
+
+// DirectiveType returns the type of the HTML directive.
+func (d *HTMLDirective) DirectiveType() string {
+	return d.Type
+}
+
+// AddHTMLStartDirective will add a directive to the response that opens the web application on the device.
+// An error is returned and no directive is added if the URI of the request does not use https.
+func (r *EchoResponse) AddHTMLStartDirective(request HTMLRequest, configuration HTMLConfig) error {
+	if err := requireHTTPS(request.URI); err != nil {
+		return err
+	}
+
+	r.Response.Directives = append(r.Response.Directives, &HTMLDirective{
+		Type:          HTMLStart,
+		Request:       &request,
+		Configuration: &configuration,
+	})
+
+	return nil
+}
+
+// AddHTMLHandleMessageDirective will add a directive to the response that sends the message to the running web application.
+func (r *EchoResponse) AddHTMLHandleMessageDirective(message json.RawMessage) *EchoResponse {
+	r.Response.Directives = append(r.Response.Directives, &HTMLDirective{
+		Type:    HTMLHandleMessage,
+		Message: message,
+	})
+
+	return r
+}
+
+// GetHTMLMessage returns the message sent by the web application in an `Alexa.Presentation.HTML.Message` request.
+func (r *EchoRequest) GetHTMLMessage() json.RawMessage {
+	return r.Request.Message
+}
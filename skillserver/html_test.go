@@ -0,0 +1,59 @@
+package skillserver
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGetHTMLMessage(t *testing.T) {
+	req, err := ParseEchoRequest(strings.NewReader(`{"request":{
+		"type": "Alexa.Presentation.HTML.Message",
+		"message": {"action": "score", "points": 10}
+	}}`))
+	if err != nil {
+		t.Fatalf("ParseEchoRequest() error = %v", err)
+	}
+
+	var message struct {
+		Action string `json:"action"`
+		Points int    `json:"points"`
+	}
+	if err := json.Unmarshal(req.GetHTMLMessage(), &message); err != nil {
+		t.Fatalf("could not decode message %s: %v", req.GetHTMLMessage(), err)
+	}
+	if message.Action != "score" || message.Points != 10 {
+		t.Errorf("message = %+v, want the message of the web application", message)
+	}
+}
+
+func TestAddHTMLStartDirective(t *testing.T) {
+	resp := NewEchoResponse()
+	err := resp.AddHTMLStartDirective(
+		HTMLRequest{URI: "https://example.com/game.html", Method: "GET", Headers: map[string]string{"Authorization": "Basic abc"}},
+		HTMLConfig{TimeoutInSeconds: 300})
+	if err != nil {
+		t.Fatalf("AddHTMLStartDirective() error = %v", err)
+	}
+	resp.AddHTMLHandleMessageDirective(json.RawMessage(`{"state":"paused"}`))
+
+	checkDirectivesJSON(t, resp, `[{
+		"type": "Alexa.Presentation.HTML.Start",
+		"request": {"uri": "https://example.com/game.html", "method": "GET", "headers": {"Authorization": "Basic abc"}},
+		"configuration": {"timeoutInSeconds": 300}
+	}, {
+		"type": "Alexa.Presentation.HTML.HandleMessage",
+		"message": {"state": "paused"}
+	}]`)
+}
+
+func TestAddHTMLStartDirectiveRequiresHTTPS(t *testing.T) {
+	resp := NewEchoResponse()
+	if err := resp.AddHTMLStartDirective(HTMLRequest{URI: "http://example.com/game.html"}, HTMLConfig{}); !errors.Is(err, ErrInsecureURL) {
+		t.Errorf("AddHTMLStartDirective() error = %v, want %v", err, ErrInsecureURL)
+	}
+	if len(resp.Response.Directives) != 0 {
+		t.Errorf("directives = %v, want none", resp.Response.Directives)
+	}
+}
@@ -0,0 +1,63 @@
+package skillserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+/**
+ * Details about hosting a skill on AWS Lambda can be found on this page:
+ * https://developer.amazon.com/docs/custom-skills/host-a-custom-skill-as-an-aws-lambda-function.html
+ */
+
+// ParseLambdaEvent decodes the event an AWS Lambda function receives from the Alexa service. The event
+// has the same shape as the body of a request sent to a web service, so the same handlers can be
+// used for both kinds of hosting.
+func ParseLambdaEvent(event []byte) (*EchoRequest, error) {
+	return ParseEchoRequest(bytes.NewReader(event))
+}
+
+// MarshalLambdaResponse encodes the response as expected as the result of an AWS Lambda function.
+func MarshalLambdaResponse(r *EchoResponse) (json.RawMessage, error) {
+	return r.String()
+}
+
+// LambdaHandler returns a handler for AWS Lambda (e.g. for `lambda.Start` of github.com/aws/aws-lambda-go)
+// that dispatches events to the handlers of the application, exactly like the web service does. Lambda functions
+// invoked by the Alexa service don't need to verify the request signature, the application ID is still checked
+// unless `WithSkipAppIDVerification` is set. Options affecting the HTTP server are ignored, as is `app.Handler`.
+func LambdaHandler(app EchoApplication, options ...Option) func(ctx context.Context, event json.RawMessage) (json.RawMessage, error) {
+	c := newConfigurator(options)
+
+	return func(ctx context.Context, event json.RawMessage) (response json.RawMessage, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				log.Printf("PANIC: %v\n%s", p, debug.Stack())
+				response, err = NewEchoResponse().OutputSpeech(c.recoverySpeech).String()
+			}
+		}()
+
+		echoReq, err := ParseLambdaEvent(event)
+		if err != nil {
+			return nil, err
+		}
+
+		if !c.skipAppIDVerification && !echoReq.VerifyAppID(app.AppID) {
+			return nil, errors.New("echo AppID mismatch")
+		}
+
+		c.intercept(echoReq)
+
+		echoResp, ok := c.dispatch(app, echoReq)
+		if !ok {
+			return nil, fmt.Errorf("%s: %s", c.invalidRequestMessage, echoReq.GetRequestType())
+		}
+
+		return c.encode(echoResp)
+	}
+}
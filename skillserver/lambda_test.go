@@ -0,0 +1,72 @@
+package skillserver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestLambdaEventRoundTrip(t *testing.T) {
+	req, err := ParseLambdaEvent([]byte(testRequestBodyWith("IntentRequest", `"intent": {"name": "HelloIntent"}`)))
+	if err != nil {
+		t.Fatalf("ParseLambdaEvent() error = %v", err)
+	}
+	if req.GetIntentName() != "HelloIntent" || req.GetSessionID() != "session" || !req.VerifyAppID(testAppID) {
+		t.Errorf("ParseLambdaEvent() = %+v, want the intent request of the event", req)
+	}
+
+	data, err := MarshalLambdaResponse(NewEchoResponse().OutputSpeech("Hello").EndSession(false))
+	if err != nil {
+		t.Fatalf("MarshalLambdaResponse() error = %v", err)
+	}
+
+	var resp struct {
+		Version  string `json:"version"`
+		Response struct {
+			OutputSpeech     EchoRespPayload `json:"outputSpeech"`
+			ShouldEndSession bool            `json:"shouldEndSession"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("could not decode response %s: %v", data, err)
+	}
+	if resp.Version != "1.0" || resp.Response.OutputSpeech.Text != "Hello" || resp.Response.ShouldEndSession {
+		t.Errorf("MarshalLambdaResponse() = %s, want the speech and an open session", data)
+	}
+}
+
+func TestLambdaHandler(t *testing.T) {
+	handler := LambdaHandler(EchoApplication{
+		AppID: testAppID,
+		OnLaunch: func(req *EchoRequest, resp *EchoResponse) {
+			resp.OutputSpeech("Hello from Lambda")
+		},
+	})
+
+	data, err := handler(context.Background(), json.RawMessage(testRequestBody("LaunchRequest")))
+	if err != nil {
+		t.Fatalf("handler() error = %v", err)
+	}
+
+	var resp struct {
+		Version  string `json:"version"`
+		Response struct {
+			OutputSpeech     EchoRespPayload `json:"outputSpeech"`
+			ShouldEndSession bool            `json:"shouldEndSession"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("could not decode response %s: %v", data, err)
+	}
+	if resp.Version != "1.0" || resp.Response.OutputSpeech.Text != "Hello from Lambda" || !resp.Response.ShouldEndSession {
+		t.Errorf("handler() = %s, want the launch speech", data)
+	}
+}
+
+func TestLambdaHandlerChecksAppID(t *testing.T) {
+	handler := LambdaHandler(EchoApplication{AppID: "amzn1.ask.skill.other"})
+
+	if _, err := handler(context.Background(), json.RawMessage(testRequestBody("LaunchRequest"))); err == nil {
+		t.Error("handler() error = nil, want an error for the mismatching application ID")
+	}
+}
@@ -0,0 +1,161 @@
+package skillserver
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+/**
+ * Details about the List Management API can be found on this page:
+ * https://developer.amazon.com/docs/custom-skills/list-management-api-reference.html
+ */
+
+// ListState is the state of a list, lists are either active or archived.
+type ListState string
+
+// ListItemStatus is the status of a single item on a list.
+type ListItemStatus string
+
+const (
+	// ListActive indicates the list is in use.
+	ListActive ListState = "active"
+	// ListArchived indicates the list has been archived by the end user.
+	ListArchived ListState = "archived"
+
+	// ListItemActive indicates the item has not been completed yet.
+	ListItemActive ListItemStatus = "active"
+	// ListItemCompleted indicates the item has been checked off the list.
+	ListItemCompleted ListItemStatus = "completed"
+)
+
+// ListsClient is used to read and manage the end user's shopping and to-do lists. The skill
+// needs to be granted the list read and/or write permissions by the end user, otherwise the calls
+// fail with a `PermissionError`.
+type ListsClient struct {
+	api apiClient
+}
+
+// ListStatusLink points to the items of a list with a specific status.
+type ListStatusLink struct {
+	Href   string         `json:"href"`
+	Status ListItemStatus `json:"status"`
+}
+
+// ListMetadata describes a single list without its items.
+type ListMetadata struct {
+	ListID    string           `json:"listId"`
+	Name      string           `json:"name"`
+	State     ListState        `json:"state"`
+	Version   int              `json:"version"`
+	StatusMap []ListStatusLink `json:"statusMap,omitempty"`
+}
+
+// List is a single list including the items that matched the requested status.
+type List struct {
+	ListID  string     `json:"listId"`
+	Name    string     `json:"name"`
+	State   ListState  `json:"state"`
+	Version int        `json:"version"`
+	Items   []ListItem `json:"items"`
+	Links   struct {
+		Next string `json:"next,omitempty"`
+	} `json:"links"`
+}
+
+// ListItem is a single item on a list.
+type ListItem struct {
+	ID          string         `json:"id"`
+	Version     int            `json:"version"`
+	Value       string         `json:"value"`
+	Status      ListItemStatus `json:"status"`
+	CreatedTime string         `json:"createdTime,omitempty"`
+	UpdatedTime string         `json:"updatedTime,omitempty"`
+	Href        string         `json:"href,omitempty"`
+}
+
+// NewListsClient constructs a ListsClient using the API endpoint and access token of the provided request.
+func NewListsClient(r *EchoRequest) *ListsClient {
+	return &ListsClient{api: newAPIClient(r)}
+}
+
+// GetListsMetadata returns the metadata of all lists of the end user.
+func (c *ListsClient) GetListsMetadata(ctx context.Context) ([]ListMetadata, error) {
+	var resp struct {
+		Lists []ListMetadata `json:"lists"`
+	}
+
+	if err := c.api.do(ctx, http.MethodGet, "/v2/householdlists/", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Lists, nil
+}
+
+// GetList returns the list with the given ID including all items with the given status.
+func (c *ListsClient) GetList(ctx context.Context, listID string, status ListItemStatus) (*List, error) {
+	list := &List{}
+	path := "/v2/householdlists/" + url.PathEscape(listID) + "/" + url.PathEscape(string(status))
+
+	if err := c.api.do(ctx, http.MethodGet, path, nil, list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// CreateList creates a new, active list with the given name.
+func (c *ListsClient) CreateList(ctx context.Context, name string) (*ListMetadata, error) {
+	req := struct {
+		Name  string    `json:"name"`
+		State ListState `json:"state"`
+	}{name, ListActive}
+	list := &ListMetadata{}
+
+	if err := c.api.do(ctx, http.MethodPost, "/v2/householdlists/", req, list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// CreateListItem adds a new item with the given value and status to a list.
+func (c *ListsClient) CreateListItem(ctx context.Context, listID, value string, status ListItemStatus) (*ListItem, error) {
+	req := struct {
+		Value  string         `json:"value"`
+		Status ListItemStatus `json:"status"`
+	}{value, status}
+	item := &ListItem{}
+	path := "/v2/householdlists/" + url.PathEscape(listID) + "/items"
+
+	if err := c.api.do(ctx, http.MethodPost, path, req, item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// UpdateListItem replaces the value and status of an existing item. The version of the item needs
+// to match the current version known to the Alexa service.
+func (c *ListsClient) UpdateListItem(ctx context.Context, listID string, item ListItem) (*ListItem, error) {
+	req := struct {
+		Value   string         `json:"value"`
+		Status  ListItemStatus `json:"status"`
+		Version int            `json:"version"`
+	}{item.Value, item.Status, item.Version}
+	updated := &ListItem{}
+	path := "/v2/householdlists/" + url.PathEscape(listID) + "/items/" + url.PathEscape(item.ID)
+
+	if err := c.api.do(ctx, http.MethodPut, path, req, updated); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// DeleteListItem removes the item with the given ID from a list.
+func (c *ListsClient) DeleteListItem(ctx context.Context, listID, itemID string) error {
+	path := "/v2/householdlists/" + url.PathEscape(listID) + "/items/" + url.PathEscape(itemID)
+
+	return c.api.do(ctx, http.MethodDelete, path, nil, nil)
+}
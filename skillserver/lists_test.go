@@ -0,0 +1,110 @@
+package skillserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Starts a fake List Management API answering calls to the path with the status and response body,
+// after checking the method and decoding the request body into in. The caller has to close the server.
+func listsTestServer(t *testing.T, method, path string, in interface{}, status int, response string) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method || r.URL.EscapedPath() != path {
+			t.Errorf("request = %s %s, want %s %s", r.Method, r.URL.EscapedPath(), method, path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer token")
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q, want %q", got, "application/json")
+		}
+		if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+			t.Errorf("could not decode request body: %v", err)
+		}
+
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+}
+
+func TestCreateList(t *testing.T) {
+	var body struct {
+		Name  string    `json:"name"`
+		State ListState `json:"state"`
+	}
+	server := listsTestServer(t, http.MethodPost, "/v2/householdlists/", &body, http.StatusCreated,
+		`{"listId":"list-1","name":"Groceries","state":"active","version":1}`)
+	defer server.Close()
+
+	list, err := NewListsClient(progressiveTestRequest(server.URL)).CreateList(context.Background(), "Groceries")
+	if err != nil {
+		t.Fatalf("CreateList() error = %v", err)
+	}
+
+	if body.Name != "Groceries" || body.State != ListActive {
+		t.Errorf("request body = %+v, want an active list named Groceries", body)
+	}
+	if list.ListID != "list-1" || list.Name != "Groceries" || list.State != ListActive || list.Version != 1 {
+		t.Errorf("CreateList() = %+v, want the created list", list)
+	}
+}
+
+func TestCreateListItem(t *testing.T) {
+	var body struct {
+		Value  string         `json:"value"`
+		Status ListItemStatus `json:"status"`
+	}
+	server := listsTestServer(t, http.MethodPost, "/v2/householdlists/list%2F1/items", &body, http.StatusCreated,
+		`{"id":"item-1","version":1,"value":"Milk","status":"active"}`)
+	defer server.Close()
+
+	client := NewListsClient(progressiveTestRequest(server.URL))
+	item, err := client.CreateListItem(context.Background(), "list/1", "Milk", ListItemActive)
+	if err != nil {
+		t.Fatalf("CreateListItem() error = %v", err)
+	}
+
+	if body.Value != "Milk" || body.Status != ListItemActive {
+		t.Errorf("request body = %+v, want an active item Milk", body)
+	}
+	if item.ID != "item-1" || item.Value != "Milk" || item.Status != ListItemActive || item.Version != 1 {
+		t.Errorf("CreateListItem() = %+v, want the created item", item)
+	}
+}
+
+func TestCreateListItemErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		check  func(error) bool
+	}{
+		{"permission missing", http.StatusForbidden, func(err error) bool {
+			var permErr *PermissionError
+			return errors.As(err, &permErr) && permErr.Message == "no access"
+		}},
+		{"other failure", http.StatusConflict, func(err error) bool {
+			var apiErr *APIError
+			return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict && apiErr.Message == "no access"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body interface{}
+			server := listsTestServer(t, http.MethodPost, "/v2/householdlists/list-1/items", &body, tt.status, `{"message":"no access"}`)
+			defer server.Close()
+
+			client := NewListsClient(progressiveTestRequest(server.URL))
+			_, err := client.CreateListItem(context.Background(), "list-1", "Milk", ListItemActive)
+			if !tt.check(err) {
+				t.Errorf("CreateListItem() error = %v (%T)", err, err)
+			}
+		})
+	}
+}
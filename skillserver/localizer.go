@@ -0,0 +1,52 @@
+package skillserver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Localizer resolves the text for a key in the locale of a request. The args are used to format
+// the resolved text.
+type Localizer interface {
+	Get(locale, key string, args ...interface{}) string
+}
+
+// MapLocalizer is a Localizer backed by a map of locales (e.g. "en-US") to keys to format strings.
+// A key missing in a locale is looked up in the language of the locale (e.g. "en") and then in the
+// Fallback locale. If none of them contains the key, the key itself is returned without formatting.
+type MapLocalizer struct {
+	Strings  map[string]map[string]string
+	Fallback string
+}
+
+// Get returns the formatted text for the key in the given locale.
+func (l MapLocalizer) Get(locale, key string, args ...interface{}) string {
+	candidates := []string{locale}
+	if i := strings.Index(locale, "-"); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	candidates = append(candidates, l.Fallback)
+
+	for _, candidate := range candidates {
+		if format, ok := l.Strings[candidate][key]; ok {
+			if len(args) == 0 {
+				return format
+			}
+
+			return fmt.Sprintf(format, args...)
+		}
+	}
+
+	// The key is not a format string, so the args are not applied to it.
+	return key
+}
+
+// Speak will set the output speech to the text resolved for the key in the locale of the request,
+// using the Localizer of the EchoApplication handling the request. Without a Localizer the key is spoken.
+func (r *EchoResponse) Speak(req *EchoRequest, key string, args ...interface{}) *EchoResponse {
+	if r.localizer == nil {
+		return r.OutputSpeech(key)
+	}
+
+	return r.OutputSpeech(r.localizer.Get(req.Locale(), key, args...))
+}
@@ -0,0 +1,54 @@
+package skillserver
+
+import (
+	"testing"
+)
+
+var testLocalizer = MapLocalizer{
+	Strings: map[string]map[string]string{
+		"en-US": {"welcome": "Welcome, %s!", "bye": "Goodbye."},
+		"de-DE": {"welcome": "Willkommen, %s!"},
+		"de":    {"bye": "Tschüss."},
+		"en":    {"help": "How can I help?"},
+	},
+	Fallback: "en",
+}
+
+func TestMapLocalizerGet(t *testing.T) {
+	tests := []struct {
+		name   string
+		locale string
+		key    string
+		args   []interface{}
+		want   string
+	}{
+		{"en-US", "en-US", "welcome", []interface{}{"Bob"}, "Welcome, Bob!"},
+		{"de-DE", "de-DE", "welcome", []interface{}{"Bob"}, "Willkommen, Bob!"},
+		{"without args", "en-US", "bye", nil, "Goodbye."},
+		{"language fallback", "de-AT", "bye", nil, "Tschüss."},
+		{"fallback locale", "de-DE", "help", nil, "How can I help?"},
+		{"missing key", "de-DE", "unknown", nil, "unknown"},
+		{"missing key with args", "en-US", "unknown", []interface{}{"Bob"}, "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testLocalizer.Get(tt.locale, tt.key, tt.args...); got != tt.want {
+				t.Errorf("Get(%q, %q) = %q, want %q", tt.locale, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpeakUsesLocaleOfRequest(t *testing.T) {
+	req := &EchoRequest{}
+	req.Request.Locale = "de-DE"
+
+	resp := NewEchoResponse()
+	resp.localizer = testLocalizer
+	resp.Speak(req, "welcome", "Bob")
+
+	if got := resp.Response.OutputSpeech.Text; got != "Willkommen, Bob!" {
+		t.Errorf("speech = %q, want %q", got, "Willkommen, Bob!")
+	}
+}
@@ -0,0 +1,96 @@
+package skillserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/urfave/negroni"
+)
+
+// Fields replaced by `WithRequestLogging` if redaction is enabled, wherever they appear in the JSON.
+var redactedFields = map[string]bool{
+	"accessToken":    true,
+	"consentToken":   true,
+	"apiAccessToken": true,
+	"deviceId":       true,
+}
+
+// WithRequestLogging logs the JSON of every echo request and of the response sent for it. If redact is set,
+// access tokens, consent tokens and device IDs are replaced before logging, so the logs don't contain them.
+func WithRequestLogging(redact bool) Option {
+	return func(c *configurator) {
+		c.requestLogging = true
+		c.redactLogs = redact
+	}
+}
+
+type recordingWriter struct {
+	http.ResponseWriter
+	body       bytes.Buffer
+	statusCode int
+}
+
+func (w *recordingWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Log the buffered request body and the response body of an echo request.
+func (c *configurator) logEcho() negroni.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		body, _ := requestBody(r)
+		log.Printf("Echo request %s: %s", r.URL.Path, c.loggable(body))
+
+		recorder := &recordingWriter{ResponseWriter: w}
+		next(recorder, r)
+
+		log.Printf("Echo response %s: %s", r.URL.Path, c.loggable(recorder.body.Bytes()))
+	}
+}
+
+func (c *configurator) loggable(body []byte) string {
+	if !c.redactLogs {
+		return string(body)
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		// Don't risk logging sensitive data that can't be redacted.
+		return "[invalid JSON, not logged]"
+	}
+
+	redacted, err := json.Marshal(redact(data))
+	if err != nil {
+		return "[invalid JSON, not logged]"
+	}
+
+	return string(redacted)
+}
+
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if redactedFields[key] {
+				v[key] = "REDACTED"
+			} else {
+				v[key] = redact(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redact(item)
+		}
+	}
+
+	return value
+}
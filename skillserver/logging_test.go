@@ -0,0 +1,73 @@
+package skillserver
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+// Returns what is logged with the standard logger while f runs.
+func captureLog(f func()) string {
+	var buf bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&buf)
+
+	f()
+
+	return buf.String()
+}
+
+func TestRequestLoggingRedactsTokens(t *testing.T) {
+	body := strings.Replace(testRequestBody("LaunchRequest"), `"device": {"deviceId": "device"}`,
+		`"device": {"deviceId": "secret-device"}, "apiAccessToken": "secret-api-token", "user": {"accessToken": "secret-access-token", "permissions": {"consentToken": "secret-consent-token"}}`, 1)
+	if body == testRequestBody("LaunchRequest") {
+		t.Fatal("could not add the tokens to the request")
+	}
+
+	tests := []struct {
+		name   string
+		redact bool
+	}{
+		{"redacted", true},
+		{"not redacted", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := testHandler(t, WithValidator(NoopValidator()), WithRequestLogging(tt.redact))
+
+			logged := captureLog(func() {
+				postTestEcho(h, body)
+			})
+
+			if !strings.Contains(logged, "Echo request /echo/test") || !strings.Contains(logged, "Echo response /echo/test") {
+				t.Fatalf("log = %q, want the request and the response", logged)
+			}
+			for _, secret := range []string{"secret-device", "secret-api-token", "secret-access-token", "secret-consent-token"} {
+				if got := strings.Contains(logged, secret); got == tt.redact {
+					t.Errorf("log contains %s: %v, want %v", secret, got, !tt.redact)
+				}
+			}
+			if got := strings.Contains(logged, `"REDACTED"`); got != tt.redact {
+				t.Errorf("log contains REDACTED: %v, want %v", got, tt.redact)
+			}
+		})
+	}
+}
+
+func TestRequestLoggerIncludesIDs(t *testing.T) {
+	h := testAppHandler(t, EchoApplication{
+		OnLaunch: func(req *EchoRequest, resp *EchoResponse) {
+			req.Logger().Print("Handling launch")
+		},
+	})
+
+	logged := captureLog(func() {
+		postTestEcho(h, testRequestBody("LaunchRequest"))
+	})
+
+	if !strings.Contains(logged, "requestId=request sessionId=session ") || !strings.Contains(logged, "Handling launch") {
+		t.Errorf("log = %q, want the message with the request and session ID", logged)
+	}
+}
@@ -0,0 +1,48 @@
+package skillserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+/**
+ * Details about the Skill Messaging API can be found on this page:
+ * https://developer.amazon.com/docs/smapi/skill-messaging-api-reference.html
+ */
+
+// MessagingClient sends messages to the skill on behalf of an end user, which are delivered to the skill
+// as `Messaging.MessageReceived` requests, see `EchoApplication.OnMessageReceived`. It is used outside of
+// a session, e.g. by a backend job reacting to an external event.
+type MessagingClient struct {
+	api apiClient
+}
+
+// NewMessagingClient constructs a MessagingClient for the regional API endpoint (e.g. "https://api.amazonalexa.com")
+// the end users are served by. The access token has to be requested from Login with Amazon using the skill's client
+// credentials and the `alexa:skill_messaging` scope.
+func NewMessagingClient(endpoint, accessToken string) *MessagingClient {
+	return &MessagingClient{api: apiClient{
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		accessToken: accessToken,
+		httpClient:  &http.Client{Timeout: time.Second * 5},
+	}}
+}
+
+// SendMessage sends the payload to the skill for the end user with the given user ID. Messages that couldn't
+// be delivered within an hour are dropped.
+func (c *MessagingClient) SendMessage(ctx context.Context, userID string, payload json.RawMessage) error {
+	message := struct {
+		Data json.RawMessage `json:"data"`
+	}{payload}
+
+	return c.api.do(ctx, http.MethodPost, "/v1/skillmessages/users/"+url.PathEscape(userID), message, nil)
+}
+
+// GetMessagePayload returns the message of a `Messaging.MessageReceived` request, as sent with `MessagingClient.SendMessage`.
+func (r *EchoRequest) GetMessagePayload() json.RawMessage {
+	return r.Request.Message
+}
@@ -0,0 +1,42 @@
+package skillserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestOnMessageReceived(t *testing.T) {
+	var payload json.RawMessage
+	h := testAppHandler(t, EchoApplication{
+		OnMessageReceived: func(req *EchoRequest, resp *EchoResponse) {
+			payload = req.GetMessagePayload()
+		},
+	})
+
+	w := postTestEcho(h, testRequestBodyWith("Messaging.MessageReceived", `"message": {"order": "shipped"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if string(payload) != `{"order": "shipped"}` {
+		t.Errorf("GetMessagePayload() = %s, want the message of the request", payload)
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	var body struct {
+		Data json.RawMessage `json:"data"`
+	}
+	server := listsTestServer(t, http.MethodPost, "/v1/skillmessages/users/user%2F1", &body, http.StatusAccepted, "")
+	defer server.Close()
+
+	client := NewMessagingClient(server.URL+"/", "token")
+	if err := client.SendMessage(context.Background(), "user/1", json.RawMessage(`{"order":"shipped"}`)); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+
+	if string(body.Data) != `{"order":"shipped"}` {
+		t.Errorf("sent data = %s, want the payload", body.Data)
+	}
+}
@@ -0,0 +1,81 @@
+package skillserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+/**
+ * Details about the Progressive Response API can be found on this page:
+ * https://developer.amazon.com/docs/custom-skills/send-the-user-a-progressive-response.html
+ */
+
+// ErrFinalResponseSent is returned when a progressive response is sent after the skill already
+// answered the request it belongs to.
+var ErrFinalResponseSent = errors.New("final response already sent")
+
+// ProgressiveResponse sends interim speech to the end user while the skill is still building its
+// response, e.g. while waiting on a slow backend. Speak can be called several times for one request,
+// also from several goroutines. The calls are serialized: each one waits until the previous post to
+// the Alexa service has completed, which is what keeps the speeches in the order of the calls. The
+// directives carry no sequence number.
+type ProgressiveResponse struct {
+	api       apiClient
+	requestID string
+
+	mu   sync.Mutex // Serializes the posts of Speak.
+	sent int        // Number of successful posts, see Sent.
+}
+
+// NewProgressiveResponse constructs a ProgressiveResponse for the provided request.
+func NewProgressiveResponse(r *EchoRequest) *ProgressiveResponse {
+	return &ProgressiveResponse{
+		api:       newAPIClient(r),
+		requestID: r.Request.RequestID,
+	}
+}
+
+// Speak sends the text or SSML speech to the device. If the Alexa service reports that the final
+// response has already been spoken, ErrFinalResponseSent is returned.
+func (p *ProgressiveResponse) Speak(ctx context.Context, speech string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	body := struct {
+		Header struct {
+			RequestID string `json:"requestId"`
+		} `json:"header"`
+		Directive struct {
+			Type   string `json:"type"`
+			Speech string `json:"speech"`
+		} `json:"directive"`
+	}{}
+	body.Header.RequestID = p.requestID
+	body.Directive.Type = "VoicePlayer.Speak"
+	body.Directive.Speech = speech
+
+	err := p.api.do(ctx, http.MethodPost, "/v1/directives", body, nil)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusPreconditionFailed {
+			return ErrFinalResponseSent
+		}
+
+		return err
+	}
+
+	p.sent++
+
+	return nil
+}
+
+// Sent returns how many progressive responses have been delivered successfully. It is only a count
+// for logging and metrics, the order of the speeches doesn't depend on it.
+func (p *ProgressiveResponse) Sent() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.sent
+}
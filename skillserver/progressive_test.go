@@ -0,0 +1,100 @@
+package skillserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// Starts a fake Alexa service API recording the speech of every progressive response. Once the
+// given number of responses has been accepted, it answers like after the final response was sent.
+// The caller has to close the server.
+func progressiveTestServer(t *testing.T, accept int) (*httptest.Server, func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var speeches []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/directives" || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+
+		var body struct {
+			Header struct {
+				RequestID string `json:"requestId"`
+			} `json:"header"`
+			Directive struct {
+				Type   string `json:"type"`
+				Speech string `json:"speech"`
+			} `json:"directive"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("could not decode progressive response: %v", err)
+		}
+		if body.Header.RequestID != "request" || body.Directive.Type != "VoicePlayer.Speak" {
+			t.Errorf("unexpected progressive response %+v", body)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(speeches) >= accept {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		speeches = append(speeches, body.Directive.Speech)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), speeches...)
+	}
+}
+
+func progressiveTestRequest(endpoint string) *EchoRequest {
+	req := &EchoRequest{}
+	req.Request.RequestID = "request"
+	req.Context.System.APIEndpoint = endpoint
+	req.Context.System.APIAccessToken = "token"
+
+	return req
+}
+
+func TestProgressiveResponseSpeaksRepeatedly(t *testing.T) {
+	server, speeches := progressiveTestServer(t, 2)
+	defer server.Close()
+	progressive := NewProgressiveResponse(progressiveTestRequest(server.URL))
+
+	for _, speech := range []string{"Looking that up.", "Almost there."} {
+		if err := progressive.Speak(context.Background(), speech); err != nil {
+			t.Fatalf("Speak(%q) error = %v", speech, err)
+		}
+	}
+
+	want := []string{"Looking that up.", "Almost there."}
+	if got := speeches(); !reflect.DeepEqual(got, want) {
+		t.Errorf("posted speeches = %v, want %v", got, want)
+	}
+	if got := progressive.Sent(); got != 2 {
+		t.Errorf("Sent() = %d, want 2", got)
+	}
+}
+
+func TestProgressiveResponseAfterFinalResponse(t *testing.T) {
+	server, _ := progressiveTestServer(t, 0)
+	defer server.Close()
+	progressive := NewProgressiveResponse(progressiveTestRequest(server.URL))
+
+	if err := progressive.Speak(context.Background(), "Too late."); !errors.Is(err, ErrFinalResponseSent) {
+		t.Errorf("Speak() error = %v, want %v", err, ErrFinalResponseSent)
+	}
+	if got := progressive.Sent(); got != 0 {
+		t.Errorf("Sent() = %d, want 0", got)
+	}
+}
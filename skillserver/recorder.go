@@ -0,0 +1,101 @@
+package skillserver
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/urfave/negroni"
+)
+
+// Interaction is an echo request received by the server together with the response sent for it.
+type Interaction struct {
+	Path       string
+	Request    []byte // The JSON body of the request.
+	StatusCode int
+	Response   []byte // The body of the response, the JSON of an EchoResponse or an error.
+}
+
+// Recorder keeps the last interactions of the echo endpoints in memory, e.g. for integration tests asserting
+// the responses without scraping logs. Register it with `WithRecorder`. It is safe for concurrent use.
+// Interactions are not recorded if the handler panics.
+type Recorder struct {
+	mu           sync.Mutex
+	size         int
+	interactions []Interaction
+}
+
+// NewRecorder returns a Recorder keeping the last size interactions, at least one.
+func NewRecorder(size int) *Recorder {
+	if size < 1 {
+		size = 1
+	}
+
+	return &Recorder{size: size}
+}
+
+// WithRecorder records the requests to and responses from the echo endpoints in the Recorder.
+func WithRecorder(recorder *Recorder) Option {
+	return func(c *configurator) {
+		c.recorder = recorder
+	}
+}
+
+// LastInteraction returns the most recent interaction. False is returned if nothing was recorded yet.
+func (r *Recorder) LastInteraction() (Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.interactions) == 0 {
+		return Interaction{}, false
+	}
+
+	return r.interactions[len(r.interactions)-1], true
+}
+
+// Interactions returns the recorded interactions, the oldest first.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Interaction(nil), r.interactions...)
+}
+
+// Reset removes all recorded interactions.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.interactions = nil
+}
+
+func (r *Recorder) record(interaction Interaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.interactions = append(r.interactions, interaction)
+	if len(r.interactions) > r.size {
+		r.interactions = append([]Interaction(nil), r.interactions[len(r.interactions)-r.size:]...)
+	}
+}
+
+// Record the buffered request body and the response of an echo request.
+func (r *Recorder) recordEcho() negroni.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+		body, _ := requestBody(req)
+
+		recorder := &recordingWriter{ResponseWriter: w}
+		next(recorder, req)
+
+		statusCode := recorder.statusCode
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+
+		r.record(Interaction{
+			Path:       req.URL.Path,
+			Request:    body,
+			StatusCode: statusCode,
+			Response:   append([]byte(nil), recorder.body.Bytes()...),
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package skillserver
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRecorderLastInteraction(t *testing.T) {
+	recorder := NewRecorder(2)
+	if _, ok := recorder.LastInteraction(); ok {
+		t.Error("LastInteraction() before any request = true, want false")
+	}
+
+	h := testHandler(t, WithValidator(NoopValidator()), WithRecorder(recorder))
+	body := testRequestBody("LaunchRequest")
+	postTestEcho(h, body)
+
+	interaction, ok := recorder.LastInteraction()
+	if !ok {
+		t.Fatal("LastInteraction() = false, want true")
+	}
+	if interaction.Path != "/echo/test" || string(interaction.Request) != body || interaction.StatusCode != http.StatusOK {
+		t.Errorf("LastInteraction() = %s %d %s, want the launch request", interaction.Path, interaction.StatusCode, interaction.Request)
+	}
+	if !strings.Contains(string(interaction.Response), `"text":"Hello"`) {
+		t.Errorf("recorded response = %s, want the launch speech", interaction.Response)
+	}
+}
+
+func TestRecorderKeepsLastInteractions(t *testing.T) {
+	recorder := NewRecorder(2)
+	h := testHandler(t, WithValidator(NoopValidator()), WithRecorder(recorder))
+
+	postTestEcho(h, testRequestBody("LaunchRequest"))
+	postTestEcho(h, "{")
+	postTestEcho(h, testRequestBody("SessionEndedRequest"))
+
+	interactions := recorder.Interactions()
+	if len(interactions) != 2 {
+		t.Fatalf("len(Interactions()) = %d, want 2", len(interactions))
+	}
+	if interactions[0].StatusCode != http.StatusBadRequest || string(interactions[0].Request) != "{" {
+		t.Errorf("first interaction = %d %s, want the rejected request", interactions[0].StatusCode, interactions[0].Request)
+	}
+	if !strings.Contains(string(interactions[1].Request), "SessionEndedRequest") {
+		t.Errorf("last interaction = %s, want the session ended request", interactions[1].Request)
+	}
+
+	recorder.Reset()
+	if _, ok := recorder.LastInteraction(); ok {
+		t.Error("LastInteraction() after Reset() = true, want false")
+	}
+}
@@ -6,22 +6,29 @@ import (
 	"crypto"
 	"crypto/rsa"
 	"crypto/sha1"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"runtime"
+	"runtime/debug"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/mikeflynn/go-alexa/skillserver/dialog"
 	"github.com/urfave/negroni"
 )
 
@@ -30,12 +37,36 @@ import (
 // to be verified to ensure the requests are coming from the correct app. Handlers can also be provied for
 // different types of requests sent by the Alexa Skills Kit such as OnLaunch or OnIntent.
 type EchoApplication struct {
-	AppID              string
-	Handler            func(http.ResponseWriter, *http.Request)
-	OnLaunch           func(*EchoRequest, *EchoResponse)
-	OnIntent           func(*EchoRequest, *EchoResponse)
-	OnSessionEnded     func(*EchoRequest, *EchoResponse)
-	OnAudioPlayerState func(*EchoRequest, *EchoResponse)
+	AppID                   string
+	Handler                 func(http.ResponseWriter, *http.Request)
+	OnLaunch                func(*EchoRequest, *EchoResponse)
+	OnIntent                func(*EchoRequest, *EchoResponse)
+	OnSessionEnded          func(*EchoRequest, *EchoResponse)
+	OnAudioPlayerState      func(*EchoRequest, *EchoResponse)
+	OnSkillEvent            map[string]func(*EchoRequest, *EchoResponse) // Keyed by event type, e.g. "AlexaSkillEvent.SkillEnabled".
+	OnDialogAPIInvoked      func(*EchoRequest, *EchoResponse)
+	OnCanFulfillIntent      func(*EchoRequest, *EchoResponse)
+	OnConnectionsResponse   func(*EchoRequest, *EchoResponse)
+	OnHTMLMessage           func(*EchoRequest, *EchoResponse)
+	OnAPLLoadIndexListData  func(*EchoRequest, *EchoResponse)
+	OnAPLLoadTokenListData  func(*EchoRequest, *EchoResponse)
+	OnMessageReceived       func(*EchoRequest, *EchoResponse)
+	OnPackageManagerRequest func(*EchoRequest, *EchoResponse) // Called for all `Alexa.DataStore.PackageManager.*` requests, the payload is in `EchoReqBody.Payload`.
+	Localizer               Localizer                         // Used by `EchoResponse.Speak` to resolve localized speech.
+
+	// OnUnknownRequest is called for request types none of the handlers above is meant for, instead of
+	// answering with the invalid request fallback. This allows handling request types added by Amazon.
+	OnUnknownRequest func(requestType string, req *EchoRequest, resp *EchoResponse)
+
+	// StaticResponses are sent for requests without a handler, e.g. to stub a skill for contract tests
+	// or demos. They are keyed by intent name (e.g. "AMAZON.HelpIntent") or request type (e.g. "LaunchRequest").
+	StaticResponses map[string]*EchoResponse
+
+	// SlotValidators check the values of the slots of an intent request before OnIntent is called. They are
+	// keyed by slot name. If a validator returns an error, the slot is elicited again with `SlotValidationPrompt`
+	// as speech and reprompt, or the error message if no prompt is set, and OnIntent isn't called.
+	SlotValidators       map[string]func(value string) error
+	SlotValidationPrompt string
 }
 
 // StdApplication is a type of application that allows the user to accept and manually process
@@ -46,16 +77,51 @@ type StdApplication struct {
 	Handler func(http.ResponseWriter, *http.Request)
 }
 
-type requestContextKey string
+// AppIDRouter hosts several EchoApplications on a single path. Incoming requests are passed to the
+// application registered for the application ID in the request body. Register it like an EchoApplication:
+//
+//	router := skillserver.AppIDRouter{}
+//	router.RegisterByAppID("amzn1.ask.skill.first", firstApp)
+//	router.RegisterByAppID("amzn1.ask.skill.second", secondApp)
+//	apps := map[string]interface{}{"/echo/skills": router}
+//
+// The router may also be registered as a pointer, e.g. `&router`.
+type AppIDRouter struct {
+	apps map[string]EchoApplication
+}
+
+// RegisterByAppID adds an application that handles the requests sent for the given application ID.
+// The AppID field of the application is ignored.
+func (a *AppIDRouter) RegisterByAppID(appID string, app EchoApplication) {
+	if a.apps == nil {
+		a.apps = make(map[string]EchoApplication)
+	}
+	app.AppID = appID
+	a.apps[appID] = app
+}
+
+// requestContextKey is the type of the keys under which the echo pipeline stores values in the request
+// context. Being unexported, no other package can create a key of this type, so the values never collide
+// with values stored by other middleware, even under a plain string key with the same name.
+// Use `WithEchoRequest` and `EchoRequestFromContext` to access the stored EchoRequest.
+type requestContextKey int
+
+const (
+	bodyKey requestContextKey = iota
+	echoRequestKey
+	echoApplicationKey
+	forwardedSchemeKey
+)
 
 var (
-	applications = map[string]interface{}{}
-	rootPrefix   = "/"
-	echoPrefix   = "/echo/"
+	rootPrefix = "/"
+	echoPrefix = "/echo/"
 )
 
 // SetEchoPrefix provides a way to specify a single path prefix that all EchoApplications will share.SetEchoPrefix
 // All incoming requests to an initialized EchoApplication will need to have a path that starts with this prefix.
+//
+// Deprecated: The prefix is shared by all servers of the process, use `WithEchoPrefix` instead.
 func SetEchoPrefix(prefix string) {
 	echoPrefix = prefix
 }
@@ -63,16 +129,64 @@ func SetEchoPrefix(prefix string) {
 // SetRootPrefix allows a single path prefix to be applied to the request path of all
 // StdApplications. All requests to the StdApplications provided will need to begin with
 // this prefix.
+//
+// Deprecated: The prefix is shared by all servers of the process, use `WithRootPrefix` instead.
 func SetRootPrefix(prefix string) {
 	rootPrefix = prefix
 }
 
 type configurator struct {
+	apps                    map[string]interface{}
 	requestValidatorOptions []RequestValidatorOption
+	responseLimits          *ResponseLimits
+	skipAppIDVerification   bool
+	recoverySpeech          string
+	validator               Validator
+	invalidRequestStatus    int
+	invalidRequestMessage   string
+	listenAddress           string
+	trustedProxy            bool
+	timeouts                ServerTimeouts
+	echoPrefix              string
+	rootPrefix              string
+	requestLogging          bool
+	redactLogs              bool
+	maxConcurrency          int
+	interceptors            []func(*EchoRequest)
+	strictJSON              bool
+	requireHTTPS            bool
+	softValidationFailure   string
+	recorder                *Recorder
+}
+
+// ServerTimeouts are applied to the http.Server started by `Run` and `RunSSL`, see the fields of
+// http.Server for their meaning. DefaultServerTimeouts protects against slow clients holding connections.
+type ServerTimeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+}
+
+// DefaultServerTimeouts are used unless `WithServerTimeouts` is provided. The Alexa service waits at most
+// 8 seconds for a response, so the write timeout leaves room for slow handlers.
+var DefaultServerTimeouts = ServerTimeouts{
+	ReadHeader: 5 * time.Second,
+	Read:       10 * time.Second,
+	Write:      30 * time.Second,
+	Idle:       120 * time.Second,
 }
 
 func newConfigurator(options []Option) *configurator {
-	c := &configurator{requestValidatorOptions: make([]RequestValidatorOption, 0)}
+	c := &configurator{
+		requestValidatorOptions: make([]RequestValidatorOption, 0),
+		recoverySpeech:          "Sorry, something went wrong. Please try again later.",
+		invalidRequestStatus:    http.StatusBadRequest,
+		invalidRequestMessage:   "Invalid request.",
+		timeouts:                DefaultServerTimeouts,
+		echoPrefix:              echoPrefix,
+		rootPrefix:              rootPrefix,
+	}
 	c.apply(options)
 	return c
 }
@@ -91,16 +205,190 @@ func WithRequestValidatorOptions(option RequestValidatorOption) Option {
 	}
 }
 
-// Run will initialize the apps provided and start an HTTP server listening on the specified port.
-func Run(apps map[string]interface{}, port string, options ...Option) {
+// WithValidator replaces the RequestValidator used to check incoming echo requests. Any
+// RequestValidatorOptions are ignored when a validator is provided.
+func WithValidator(v Validator) Option {
+	return func(c *configurator) {
+		c.validator = v
+	}
+}
+
+// WithResponseLimits enables checking every response built by an EchoApplication against the provided
+// limits before it is sent. Responses exceeding them are logged and, if `limits.Reject` is set,
+// replaced by an internal server error so oversized responses are noticed during development.
+func WithResponseLimits(limits ResponseLimits) Option {
+	return func(c *configurator) {
+		c.responseLimits = &limits
+	}
+}
+
+// WithSoftValidationFailure answers requests failing the signature or certificate checks with a valid
+// response speaking the provided text and status 200, instead of a 401. This helps finding out why requests
+// fail, e.g. behind a proxy stripping the signature headers, as the end user hears the speech.
+//
+// This reduces security: anyone can make the server respond, without reaching any EchoApplication.
+// Amazon requires a skill to answer invalid requests with an error to pass certification.
+func WithSoftValidationFailure(speech string) Option {
+	return func(c *configurator) {
+		c.softValidationFailure = speech
+	}
+}
+
+// WithSkipAppIDVerification disables checking the application ID of incoming requests against the
+// AppID of the EchoApplication. The timestamp and signature of the request are still verified.
+// This is meant for local testing only, a skill has to verify the application ID to pass certification.
+func WithSkipAppIDVerification(skip bool) Option {
+	return func(c *configurator) {
+		c.skipAppIDVerification = skip
+	}
+}
+
+// WithRecoverySpeech sets the text that is spoken to the end user if an EchoApplication's handler panics.
+func WithRecoverySpeech(speech string) Option {
+	return func(c *configurator) {
+		c.recoverySpeech = speech
+	}
+}
+
+// WithInvalidRequestFallback sets how EchoApplications answer requests of a type they don't handle.
+// By default they respond with a 400 "Invalid request.". If the status is http.StatusOK, a valid
+// Alexa response speaking the message is sent instead, so the end user hears an apology.
+func WithInvalidRequestFallback(status int, message string) Option {
+	return func(c *configurator) {
+		c.invalidRequestStatus = status
+		c.invalidRequestMessage = message
+	}
+}
+
+// WithListenAddress binds the server started by `Run` or `RunSSL` to the provided host or IP address,
+// e.g. "127.0.0.1" when running behind a reverse proxy. By default the server listens on all interfaces.
+func WithListenAddress(addr string) Option {
+	return func(c *configurator) {
+		c.listenAddress = addr
+	}
+}
+
+// WithEchoPrefix sets the path prefix shared by all EchoApplications of the server, "/echo/" by default.
+// Unlike `SetEchoPrefix` it only affects the server it is passed to.
+func WithEchoPrefix(prefix string) Option {
+	return func(c *configurator) {
+		c.echoPrefix = prefix
+	}
+}
+
+// WithRootPrefix sets the path prefix shared by all StdApplications of the server, "/" by default.
+// Unlike `SetRootPrefix` it only affects the server it is passed to.
+func WithRootPrefix(prefix string) Option {
+	return func(c *configurator) {
+		c.rootPrefix = prefix
+	}
+}
+
+// WithRequestInterceptor adds a function that is called with every echo request after it was verified and
+// before it is handled by the EchoApplication. Interceptors are called in the order they were added and can
+// pass values to the handlers with `EchoRequest.RequestAttributes`.
+func WithRequestInterceptor(interceptor func(*EchoRequest)) Option {
+	return func(c *configurator) {
+		c.interceptors = append(c.interceptors, interceptor)
+	}
+}
+
+func (c *configurator) intercept(echoReq *EchoRequest) {
+	for _, interceptor := range c.interceptors {
+		interceptor(echoReq)
+	}
+}
+
+// WithStrictJSON rejects echo requests containing fields that are not modelled by EchoRequest, to notice
+// changes of the request format during development. The fields Amazon sends today are modelled, e.g.
+// `shouldLinkResultBeReturned` of launch requests and the permission scopes of the user. The context and
+// intent are not checked, as they are kept as received. Leave this disabled in production, Amazon adds new
+// fields to requests at any time.
+func WithStrictJSON(strict bool) Option {
+	return func(c *configurator) {
+		c.strictJSON = strict
+	}
+}
+
+// WithMaxConcurrency limits the number of echo requests handled at the same time. Requests exceeding
+// the limit are answered with a 503 right away. By default the number is unlimited.
+func WithMaxConcurrency(n int) Option {
+	return func(c *configurator) {
+		c.maxConcurrency = n
+	}
+}
+
+// Answer echo requests with a 503 while the given number of requests is in flight.
+func limitConcurrency(n int) negroni.HandlerFunc {
+	inFlight := make(chan struct{}, n)
+
+	return func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		select {
+		case inFlight <- struct{}{}:
+			defer func() { <-inFlight }()
+			next(w, r)
+		default:
+			echoError(w, "Too many concurrent requests.", "Service Unavailable", http.StatusServiceUnavailable)
+		}
+	}
+}
+
+// WithServerTimeouts sets the timeouts of the server started by `Run` or `RunSSL`. A zero value disables
+// the respective timeout.
+func WithServerTimeouts(timeouts ServerTimeouts) Option {
+	return func(c *configurator) {
+		c.timeouts = timeouts
+	}
+}
+
+// Build the server started by `Run` and `RunSSL`.
+func (c *configurator) newServer(port string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              net.JoinHostPort(c.listenAddress, port),
+		Handler:           handler,
+		ReadHeaderTimeout: c.timeouts.ReadHeader,
+		ReadTimeout:       c.timeouts.Read,
+		WriteTimeout:      c.timeouts.Write,
+		IdleTimeout:       c.timeouts.Idle,
+	}
+}
+
+// Handler initializes the apps provided and returns the resulting http.Handler without starting a server.
+// This can be used to mount the skills in an existing server or to test them with `httptest`. Unlike `Run`,
+// Handler can't tell when the handler is no longer used, so it never closes the validator it creates, whose
+// idle connections time out after 90 seconds. To release them on shutdown, the caller owns the validator:
+// create it with `NewRequestValidator`, pass it with `WithValidator` and call its Close method when done.
+func Handler(apps map[string]interface{}, options ...Option) (http.Handler, error) {
 	router := mux.NewRouter()
-	if err := initialize(apps, router, options...); nil != err {
-		log.Fatal(err)
+	if _, err := initialize(apps, router, newConfigurator(options)); err != nil {
+		return nil, err
 	}
 
-	n := negroni.Classic()
-	n.UseHandler(router)
-	n.Run(":" + port)
+	return router, nil
+}
+
+// WithTrustedProxy makes the server use the `X-Forwarded-Proto` and `X-Forwarded-Host` headers set
+// by a reverse proxy or a development tunnel (e.g. ngrok) as the scheme and host of incoming requests.
+// This affects `ExternalURL` and `WithRequireHTTPS`. Only enable this if all requests are passed through a proxy setting these headers.
+func WithTrustedProxy(trusted bool) Option {
+	return func(c *configurator) {
+		c.trustedProxy = trusted
+	}
+}
+
+// WithRequireHTTPS rejects echo requests that were not received over https with a 400 error. Behind a proxy
+// terminating TLS, enable `WithTrustedProxy` so the scheme is taken from the `X-Forwarded-Proto` header, otherwise
+// only requests received over TLS by this server pass. The Alexa service only connects over https, so this
+// catches a misconfigured proxy forwarding plain http requests.
+func WithRequireHTTPS(require bool) Option {
+	return func(c *configurator) {
+		c.requireHTTPS = require
+	}
+}
+
+// Run will initialize the apps provided and start an HTTP server listening on the specified port.
+func Run(apps map[string]interface{}, port string, options ...Option) {
+	log.Fatal(serve(apps, port, "", "", newConfigurator(options)))
 }
 
 // RunSSL takes in a map of application, server port, certificate and key files, and
@@ -112,9 +400,77 @@ func Run(apps map[string]interface{}, port string, options ...Option) {
 // For generating a testing cert and key, read the following:
 // https://developer.amazon.com/docs/custom-skills/configure-web-service-self-signed-certificate.html
 func RunSSL(apps map[string]interface{}, port, cert, key string, options ...Option) {
+	log.Fatal(serve(apps, port, cert, key, newConfigurator(options)))
+}
+
+// Config describes a server declaratively, as an alternative to the variadic options of `Run` and `RunSSL`.
+// Zero values keep the defaults of the respective option.
+type Config struct {
+	Apps          map[string]interface{}
+	Port          string
+	ListenAddress string // See `WithListenAddress`.
+
+	EchoPrefix string // See `WithEchoPrefix`.
+	RootPrefix string // See `WithRootPrefix`.
+
+	// A TLS server is started if both files are set, see `RunSSL`.
+	CertFile string
+	KeyFile  string
+
+	Timeouts                *ServerTimeouts // See `WithServerTimeouts`.
+	Validator               Validator       // See `WithValidator`.
+	RequestValidatorOptions []RequestValidatorOption
+
+	Options []Option // Applied after the fields above.
+}
+
+func (config Config) options() []Option {
+	options := []Option{func(c *configurator) {
+		c.listenAddress = config.ListenAddress
+		c.validator = config.Validator
+		c.requestValidatorOptions = append(c.requestValidatorOptions, config.RequestValidatorOptions...)
+		if config.EchoPrefix != "" {
+			c.echoPrefix = config.EchoPrefix
+		}
+		if config.RootPrefix != "" {
+			c.rootPrefix = config.RootPrefix
+		}
+		if config.Timeouts != nil {
+			c.timeouts = *config.Timeouts
+		}
+	}}
+
+	return append(options, config.Options...)
+}
+
+// Handler initializes the apps of the config and returns the resulting http.Handler without starting a server.
+// See `Handler` for closing the validator.
+func (config Config) Handler() (http.Handler, error) {
+	return Handler(config.Apps, config.options()...)
+}
+
+// RunConfig initializes the apps of the config and starts a server as configured. Unlike `Run` and `RunSSL`
+// it returns the error instead of exiting the process.
+func RunConfig(config Config) error {
+	return serve(config.Apps, config.Port, config.CertFile, config.KeyFile, newConfigurator(config.options()))
+}
+
+// Initialize the apps and start an HTTP server, or a TLS server if the cert and key files are provided.
+func serve(apps map[string]interface{}, port, cert, key string, configurator *configurator) error {
 	router := mux.NewRouter()
-	if err := initialize(apps, router, options...); nil != err {
-		log.Fatal(err)
+	requestValidator, err := initialize(apps, router, configurator)
+	if nil != err {
+		return err
+	}
+	defer closeValidator(requestValidator)
+
+	if cert == "" && key == "" {
+		n := negroni.Classic()
+		n.UseHandler(router)
+
+		srv := configurator.newServer(port, n)
+		log.Printf("listening on %s", srv.Addr)
+		return srv.ListenAndServe()
 	}
 
 	// This is very limited TLS configuration which is required to connect alexa to our webservice.
@@ -154,18 +510,46 @@ func RunSSL(apps map[string]interface{}, port, cert, key string, options ...Opti
 			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
 		},
 	}
-	srv := &http.Server{
-		Addr:         ":" + port,
-		Handler:      router,
-		TLSConfig:    cfg,
-		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0),
+	srv := configurator.newServer(port, router)
+	srv.TLSConfig = cfg
+	srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0)
+	return srv.ListenAndServeTLS(cert, key)
+}
+
+// ErrPrefixConflict is returned when the echo and root prefixes overlap in a way that makes
+// some of the registered applications unreachable.
+var ErrPrefixConflict = errors.New("conflicting path prefixes")
+
+// Check that no StdApplication is shadowed by the echo prefix. The echo prefix is mounted first, so
+// a root prefix or StdApplication path beneath it would never receive a request.
+func validatePrefixes(apps map[string]interface{}, echoPrefix, rootPrefix string) error {
+	for uri, meta := range apps {
+		if _, ok := meta.(StdApplication); !ok {
+			continue
+		}
+
+		if strings.HasPrefix(rootPrefix, echoPrefix) {
+			return fmt.Errorf("%w: root prefix %q is within echo prefix %q", ErrPrefixConflict, rootPrefix, echoPrefix)
+		}
+
+		if strings.HasPrefix(uri, echoPrefix) {
+			return fmt.Errorf("%w: StdApplication %q is within echo prefix %q", ErrPrefixConflict, uri, echoPrefix)
+		}
 	}
-	log.Fatal(srv.ListenAndServeTLS(cert, key))
+
+	return nil
 }
 
-func initialize(apps map[string]interface{}, router *mux.Router, options ...Option) error {
-	configurator := newConfigurator(options)
-	applications = apps
+func initialize(apps map[string]interface{}, router *mux.Router, configurator *configurator) (Validator, error) {
+	if err := validatePrefixes(apps, configurator.echoPrefix, configurator.rootPrefix); err != nil {
+		return nil, err
+	}
+
+	configurator.apps = apps
+
+	if configurator.trustedProxy {
+		router.Use(forwardedHeaders)
+	}
 
 	// /echo/* Endpoints
 	echoRouter := mux.NewRouter()
@@ -174,73 +558,355 @@ func initialize(apps map[string]interface{}, router *mux.Router, options ...Opti
 
 	hasPageRouter := false
 
-	for uri, meta := range applications {
+	for uri, meta := range apps {
 		switch app := meta.(type) {
 		case EchoApplication:
-			handlerFunc := func(w http.ResponseWriter, r *http.Request) {
-				echoReq := GetEchoRequest(r)
-				echoResp := NewEchoResponse()
-
-				if echoReq.GetRequestType() == "LaunchRequest" {
-					if app.OnLaunch != nil {
-						app.OnLaunch(echoReq, echoResp)
-					}
-				} else if echoReq.GetRequestType() == "IntentRequest" {
-					if app.OnIntent != nil {
-						app.OnIntent(echoReq, echoResp)
-					}
-				} else if echoReq.GetRequestType() == "SessionEndedRequest" {
-					if app.OnSessionEnded != nil {
-						app.OnSessionEnded(echoReq, echoResp)
-					}
-				} else if strings.HasPrefix(echoReq.GetRequestType(), "AudioPlayer.") {
-					if app.OnAudioPlayerState != nil {
-						app.OnAudioPlayerState(echoReq, echoResp)
-					}
-				} else {
-					http.Error(w, "Invalid request.", http.StatusBadRequest)
-				}
-
-				json, _ := echoResp.String()
-				w.Header().Set("Content-Type", "application/json;charset=UTF-8")
-				w.Write(json)
-			}
-
-			if app.Handler != nil {
-				handlerFunc = app.Handler
-			}
-
-			echoRouter.HandleFunc(uri, handlerFunc).Methods("POST")
+			echoRouter.HandleFunc(uri, configurator.echoHandler(app)).Methods("POST")
+		case AppIDRouter, *AppIDRouter:
+			echoRouter.HandleFunc(uri, func(w http.ResponseWriter, r *http.Request) {
+				// The application was already looked up by verifyJSON.
+				app, _ := GetEchoApplication(r)
+				configurator.echoHandler(app)(w, r)
+			}).Methods("POST")
 		case StdApplication:
 			hasPageRouter = true
 			pageRouter.HandleFunc(uri, app.Handler).Methods(app.Methods)
 		}
 	}
 
-	requestValidator, err := NewRequestValidator(
-		configurator.requestValidatorOptions...,
-	)
-	if nil != err {
-		return fmt.Errorf("failed initializing request validator: %w", err)
+	if configurator.skipAppIDVerification {
+		log.Println("WARNING: application ID verification is disabled, do not use this in production.")
 	}
-	router.PathPrefix(echoPrefix).Handler(negroni.New(
-		negroni.HandlerFunc(requestValidator.validateRequest),
-		negroni.HandlerFunc(verifyJSON),
-		negroni.Wrap(echoRouter),
-	))
+
+	requestValidator := configurator.validator
+	if requestValidator == nil {
+		var err error
+		requestValidator, err = NewRequestValidator(
+			configurator.requestValidatorOptions...,
+		)
+		if nil != err {
+			return nil, fmt.Errorf("failed initializing request validator: %w", err)
+		}
+	}
+	echoPipeline := negroni.New(negroni.HandlerFunc(configurator.recoverEcho))
+	if configurator.requireHTTPS {
+		echoPipeline.Use(negroni.HandlerFunc(requireHTTPSRequest))
+	}
+	if configurator.maxConcurrency > 0 {
+		echoPipeline.Use(limitConcurrency(configurator.maxConcurrency))
+	}
+	echoPipeline.Use(negroni.HandlerFunc(bufferBody))
+	if configurator.recorder != nil {
+		echoPipeline.Use(configurator.recorder.recordEcho())
+	}
+	if configurator.requestLogging {
+		echoPipeline.Use(configurator.logEcho())
+	}
+	echoPipeline.Use(validateRequest(requestValidator, configurator.softValidationFailure))
+	echoPipeline.Use(negroni.HandlerFunc(configurator.verifyJSON))
+	echoPipeline.UseHandler(echoRouter)
+	router.PathPrefix(configurator.echoPrefix).Handler(echoPipeline)
 
 	if hasPageRouter {
-		router.PathPrefix(rootPrefix).Handler(negroni.New(
+		router.PathPrefix(configurator.rootPrefix).Handler(negroni.New(
 			negroni.Wrap(pageRouter),
 		))
 	}
+	return requestValidator, nil
+}
+
+// Returns the handler of the application for the request type, which is nil if the application doesn't
+// set one. The second value is false if the request type is not one an EchoApplication can handle.
+func (app EchoApplication) requestHandler(requestType string) (func(*EchoRequest, *EchoResponse), bool) {
+	switch {
+	case requestType == "LaunchRequest":
+		return app.OnLaunch, true
+	case requestType == "IntentRequest":
+		return app.OnIntent, true
+	case requestType == "SessionEndedRequest":
+		return app.OnSessionEnded, true
+	case strings.HasPrefix(requestType, "AudioPlayer."):
+		return app.OnAudioPlayerState, true
+	case requestType == "CanFulfillIntentRequest":
+		return app.OnCanFulfillIntent, true
+	case requestType == "Connections.Response":
+		return app.OnConnectionsResponse, true
+	case requestType == "Dialog.API.Invoked":
+		return app.OnDialogAPIInvoked, true
+	case requestType == "Alexa.Presentation.HTML.Message":
+		return app.OnHTMLMessage, true
+	case requestType == "Alexa.Presentation.APL.LoadIndexListData":
+		return app.OnAPLLoadIndexListData, true
+	case requestType == "Alexa.Presentation.APL.LoadTokenListData":
+		return app.OnAPLLoadTokenListData, true
+	case requestType == "Messaging.MessageReceived":
+		return app.OnMessageReceived, true
+	case strings.HasPrefix(requestType, "Alexa.DataStore.PackageManager."):
+		return app.OnPackageManagerRequest, true
+	case strings.HasPrefix(requestType, "AlexaSkillEvent."):
+		return app.OnSkillEvent[requestType], true
+	}
+
+	return nil, false
+}
+
+// Returns a copy of the static response configured for the request, looking up the intent name of
+// an intent request before the request type.
+func (app EchoApplication) staticResponse(echoReq *EchoRequest) (*EchoResponse, bool) {
+	static, ok := app.StaticResponses[echoReq.GetIntentName()]
+	if !ok || echoReq.GetRequestType() != "IntentRequest" {
+		static, ok = app.StaticResponses[echoReq.GetRequestType()]
+	}
+	if !ok || static == nil {
+		return nil, false
+	}
+
+	response := *static
+	return &response, true
+}
+
+// Runs the slot validators of the application on an intent request. The first slot with an invalid value is
+// elicited again, in which case true is returned. Slots are checked in alphabetical order.
+func (app EchoApplication) elicitInvalidSlot(echoReq *EchoRequest, echoResp *EchoResponse) bool {
+	if echoReq.GetRequestType() != "IntentRequest" || len(app.SlotValidators) == 0 {
+		return false
+	}
+
+	names := make([]string, 0, len(app.SlotValidators))
+	for name := range app.SlotValidators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		slot, ok := echoReq.GetSlot(name)
+		if !ok || slot.Value == "" {
+			continue
+		}
+
+		err := app.SlotValidators[name](slot.Value)
+		if err == nil {
+			continue
+		}
+
+		prompt := app.SlotValidationPrompt
+		if prompt == "" {
+			prompt = err.Error()
+		}
+
+		// Send the intent back without the invalid value.
+		intent := echoReq.Request.Intent
+		intent.Slots = echoReq.GetSlots()
+		intent.Slots[name] = Slot{Name: name, ConfirmationStatus: ConfNone}
+
+		echoResp.OutputSpeech(prompt).
+			Reprompt(prompt).
+			RespondToIntent(dialog.ElicitSlot, &intent, slot).
+			EndSession(false)
+		return true
+	}
+
+	return false
+}
+
+// Build the response of the application to the request, dispatching it by request type. False is returned
+// if the application can't handle the request and the invalid request fallback doesn't answer with speech.
+func (c *configurator) dispatch(app EchoApplication, echoReq *EchoRequest) (*EchoResponse, bool) {
+	echoResp := NewEchoResponse()
+	echoResp.localizer = app.Localizer
+
+	handler, known := app.requestHandler(echoReq.GetRequestType())
+	if app.elicitInvalidSlot(echoReq, echoResp) {
+		// The end user is asked for the slot again.
+	} else if handler != nil {
+		handler(echoReq, echoResp)
+	} else if static, ok := app.staticResponse(echoReq); ok {
+		echoResp = static
+	} else if known {
+		// Answer request types the application doesn't handle with an empty response.
+	} else if app.OnUnknownRequest != nil {
+		app.OnUnknownRequest(echoReq.GetRequestType(), echoReq, echoResp)
+	} else if c.invalidRequestStatus == http.StatusOK {
+		echoResp.OutputSpeech(c.invalidRequestMessage)
+	} else {
+		return nil, false
+	}
+
+	if strings.HasPrefix(echoReq.GetRequestType(), "AudioPlayer.") {
+		if dropped := echoResp.normalizeAudioPlayerResponse(echoReq.GetRequestType()); len(dropped) > 0 {
+			echoReq.Logger().Printf("Removed %s from the response to %s, they are not allowed.", strings.Join(dropped, ", "), echoReq.GetRequestType())
+		}
+	}
+
+	if echoResp.Version == "" {
+		echoResp.Version = "1.0"
+	}
+
+	return echoResp, true
+}
+
+// Encode the response, falling back to the default response if it can't be encoded. An error is
+// returned if the response exceeds the configured limits and should be rejected.
+func (c *configurator) encode(echoResp *EchoResponse) ([]byte, error) {
+	json, err := echoResp.String()
+	if err != nil {
+		// Never send an empty body, the Alexa service would tell the end user the skill failed.
+		log.Println("Could not encode response:", err)
+		json, _ = NewEchoResponse().String()
+	}
+
+	if c.responseLimits != nil {
+		if err := echoResp.CheckLimits(*c.responseLimits); err != nil {
+			log.Println("Response exceeds limits:", err)
+			if c.responseLimits.Reject {
+				return nil, err
+			}
+		}
+	}
+
+	return json, nil
+}
+
+// Build the handler dispatching the echo requests of the given application by request type.
+func (c *configurator) echoHandler(app EchoApplication) http.HandlerFunc {
+	if app.Handler != nil {
+		return app.Handler
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		echoResp, ok := c.dispatch(app, GetEchoRequest(r))
+		if !ok {
+			echoError(w, "", c.invalidRequestMessage, c.invalidRequestStatus)
+			return
+		}
+
+		json, err := c.encode(echoResp)
+		if err != nil {
+			echoError(w, "", "Internal Error", 500)
+			return
+		}
+		writeJSONBody(w, json)
+	}
+}
+
+// WriteJSON is a convenience method for writing the JSON encoding of v to the HTTP response, e.g. from
+// the handler of a `StdApplication`. If v can't be encoded, the error is logged, a 500 error is written
+// instead and the encoding error is returned.
+func WriteJSON(w http.ResponseWriter, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		echoError(w, "Could not encode response: "+err.Error(), "Internal Error", 500)
+		return err
+	}
+
+	writeJSONBody(w, body)
+
 	return nil
 }
 
+// WriteEcho is a convenience method for writing the EchoResponse to the HTTP response. Like the echo
+// endpoints it never sends an empty body: if the response can't be encoded, the error is logged, the
+// default response is written instead and the encoding error is returned.
+func WriteEcho(w http.ResponseWriter, echoResp *EchoResponse) error {
+	body, err := echoResp.String()
+	if err != nil {
+		log.Println("Could not encode response:", err)
+		body, _ = NewEchoResponse().String()
+	}
+
+	writeJSONBody(w, body)
+
+	return err
+}
+
+// Write the already encoded JSON body with the content type expected by the Alexa service.
+func writeJSONBody(w http.ResponseWriter, body []byte) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.Write(body)
+}
+
+// ExternalURL builds the absolute URL under which the provided path is reachable by clients, using
+// the scheme and host of the incoming request. Behind a reverse proxy, enable `WithTrustedProxy`
+// to build the URL from the forwarded headers instead of the proxy's internal address.
+func ExternalURL(r *http.Request, path string) string {
+	return requestScheme(r) + "://" + r.Host + path
+}
+
+// Returns the scheme of the request as forwarded by a trusted proxy, or the one the request was received with.
+// The scheme of `r.URL` is never used, a client sets it by sending the request line in absolute form.
+func requestScheme(r *http.Request) string {
+	if scheme, ok := r.Context().Value(forwardedSchemeKey).(string); ok {
+		return scheme
+	}
+
+	if r.TLS != nil {
+		return "https"
+	}
+
+	return "http"
+}
+
+// Reject echo requests that were not received over https.
+func requireHTTPSRequest(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if requestScheme(r) != "https" {
+		echoError(w, "Request was not received over https.", "Bad Request", 400)
+		return
+	}
+
+	next(w, r)
+}
+
+// Apply the scheme and host forwarded by a trusted proxy to the request.
+func forwardedHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+			scheme := strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0]))
+			r = r.WithContext(context.WithValue(r.Context(), forwardedSchemeKey, scheme))
+		}
+
+		if host := r.Header.Get("X-Forwarded-Host"); host != "" {
+			r.Host = strings.TrimSpace(strings.Split(host, ",")[0])
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // GetEchoRequest is a convenience method for retrieving and casting an `EchoRequest` out of a
-// standard `http.Request`.
+// standard `http.Request`. It returns nil if the request didn't pass through the echo pipeline.
 func GetEchoRequest(r *http.Request) *EchoRequest {
-	return r.Context().Value(requestContextKey("echoRequest")).(*EchoRequest)
+	echoReq, _ := EchoRequestFromContext(r.Context())
+	return echoReq
+}
+
+// WithEchoRequest returns a copy of the context carrying the EchoRequest, e.g. for custom middleware
+// that parses requests itself or for tests of a `Handler`. It can be read with `EchoRequestFromContext`.
+func WithEchoRequest(ctx context.Context, echoReq *EchoRequest) context.Context {
+	return context.WithValue(ctx, echoRequestKey, echoReq)
+}
+
+// EchoRequestFromContext returns the EchoRequest stored in the context by the echo pipeline or `WithEchoRequest`.
+func EchoRequestFromContext(ctx context.Context) (*EchoRequest, bool) {
+	echoReq, ok := ctx.Value(echoRequestKey).(*EchoRequest)
+	return echoReq, ok && echoReq != nil
+}
+
+// GetEchoApplication returns the EchoApplication the request was routed to. This allows a `Handler` shared
+// by several applications to read the AppID or other fields of the application handling the request.
+func GetEchoApplication(r *http.Request) (EchoApplication, bool) {
+	app, ok := r.Context().Value(echoApplicationKey).(EchoApplication)
+	return app, ok
+}
+
+// GetRequestAttributes returns the request attributes of the EchoRequest of the http.Request, see
+// `EchoRequest.RequestAttributes`. It returns nil if the request didn't pass through the echo pipeline.
+func GetRequestAttributes(r *http.Request) map[string]interface{} {
+	echoReq := GetEchoRequest(r)
+	if echoReq == nil {
+		return nil
+	}
+
+	return echoReq.RequestAttributes()
 }
 
 // HTTPError is a convenience method for logging a message and writing the provided error message
@@ -253,40 +919,209 @@ func HTTPError(w http.ResponseWriter, logMsg string, err string, errCode int) {
 	http.Error(w, err, errCode)
 }
 
+// echoError is used instead of HTTPError on the echo endpoints. It writes the error as a JSON
+// object, e.g. `{"error":"Bad Request"}`, so clients expecting JSON can parse it.
+func echoError(w http.ResponseWriter, logMsg string, err string, errCode int) {
+	if logMsg != "" {
+		log.Println(logMsg)
+	}
+
+	body, _ := json.Marshal(struct {
+		Error string `json:"error"`
+	}{err})
+
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(errCode)
+	w.Write(body)
+}
+
+// Recover from a panic in an echo handler by logging the stack and answering with the recovery speech.
+// The response is sent with status 200 as the Alexa service can't speak anything else.
+func (c *configurator) recoverEcho(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("PANIC: %v\n%s", err, debug.Stack())
+
+			WriteEcho(w, NewEchoResponse().OutputSpeech(c.recoverySpeech))
+		}
+	}()
+
+	next(w, r)
+}
+
+// Read the request body once, so the signature check and the JSON decoding work on exactly the same bytes.
+func bufferBody(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		echoError(w, err.Error(), "Bad Request", 400)
+		return
+	}
+
+	if len(body) == 0 {
+		echoError(w, "Request rejected: "+ErrEmptyBody.Error(), "Bad Request: empty body", 400)
+		return
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), bodyKey, body))
+
+	next(w, r)
+}
+
+// requestBody returns the body buffered by `bufferBody`. Requests that didn't pass through the middleware
+// are read and their body is replaced so it can be read again.
+func requestBody(r *http.Request) ([]byte, error) {
+	if body, ok := r.Context().Value(bodyKey).([]byte); ok {
+		return body, nil
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
 // Decode the JSON request and verify it.
-func verifyJSON(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	var echoReq *EchoRequest
-	err := json.NewDecoder(r.Body).Decode(&echoReq)
+func (c *configurator) verifyJSON(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	body, err := requestBody(r)
+	if err != nil {
+		echoError(w, err.Error(), "Bad Request", 400)
+		return
+	}
+
+	echoReq, err := ParseEchoRequest(bytes.NewReader(body))
 	if err != nil {
-		HTTPError(w, err.Error(), "Bad Request", 400)
+		switch {
+		case errors.Is(err, ErrEmptyBody):
+			echoError(w, "Request rejected: "+err.Error(), "Bad Request: empty body", 400)
+		case errors.Is(err, ErrInvalidJSON):
+			echoError(w, "Request rejected: "+err.Error(), "Bad Request: invalid JSON", 400)
+		case errors.Is(err, ErrNotAlexaRequest):
+			echoError(w, "Request rejected: "+err.Error()+", missing request.type", "Bad Request: not an Alexa request", 400)
+		default:
+			echoError(w, err.Error(), "Bad Request", 400)
+		}
 		return
 	}
 
+	if c.strictJSON {
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&EchoRequest{}); err != nil {
+			echoError(w, "Request has unexpected fields: "+err.Error(), "Bad Request", 400)
+			return
+		}
+	}
+
 	// Check the timestamp
 	if !echoReq.VerifyTimestamp() && r.URL.Query().Get("_dev") == "" {
-		HTTPError(w, "Request too old to continue (>150s).", "Bad Request", 400)
+		echoError(w, "Request timestamp differs from the server time by more than 150s.", "Bad Request", 400)
 		return
 	}
 
 	// Check the app id
-	if !echoReq.VerifyAppID(applications[r.URL.Path].(EchoApplication).AppID) {
-		HTTPError(w, "Echo AppID mismatch!", "Bad Request", 400)
+	var app EchoApplication
+	var ok bool
+	switch meta := c.apps[r.URL.Path].(type) {
+	case EchoApplication:
+		app, ok = meta, true
+	case AppIDRouter:
+		app, ok = meta.apps[echoReq.applicationID()]
+	case *AppIDRouter:
+		if meta != nil {
+			app, ok = meta.apps[echoReq.applicationID()]
+		}
+	}
+	if !ok {
+		echoError(w, "No EchoApplication registered for "+r.URL.Path, "Not Found", 404)
 		return
 	}
 
-	r = r.WithContext(context.WithValue(r.Context(), requestContextKey("echoRequest"), echoReq))
+	if !c.skipAppIDVerification && !echoReq.VerifyAppID(app.AppID) {
+		echoError(w, "Echo AppID mismatch!", "Bad Request", 400)
+		return
+	}
+
+	c.intercept(echoReq)
+
+	ctx := WithEchoRequest(r.Context(), echoReq)
+	ctx = context.WithValue(ctx, echoApplicationKey, app)
+	r = r.WithContext(ctx)
 
 	next(w, r)
 }
 
+// Validator checks that an incoming request was sent by the Alexa service. RequestValidator implements
+// all checks required by Amazon.
+type Validator interface {
+	Validate(request *http.Request) error
+}
+
+type noopValidator struct{}
+
+func (noopValidator) Validate(*http.Request) error {
+	return nil
+}
+
+// NoopValidator returns a Validator that accepts every request without checking its signature. It is
+// meant for tests that send unsigned requests, see `WithValidator`. NEVER use it in production.
+func NoopValidator() Validator {
+	return noopValidator{}
+}
+
 type RequestValidator struct {
 	client             *http.Client
 	insecureSkipVerify bool
 	timeout            time.Duration
+	certFetchRetries   int
+	certFetchBackoff   time.Duration
+	pinnedSPKI         map[string]bool
+	certInspector      func(*x509.Certificate)
+	certNames          []string
+	certFetchLimiter   *certFetchLimiter
+	certFetches        *certFetchGroup
+}
+
+// certFetchLimiter counts certificate downloads in fixed windows. It is shared by all copies of a RequestValidator.
+type certFetchLimiter struct {
+	mu     sync.Mutex
+	window time.Duration
+	global int
+	perURL int
+	start  time.Time
+	total  int
+	byURL  map[string]int
+}
+
+// Returns whether another download of the certificate is allowed in the current window and counts it if so.
+func (l *certFetchLimiter) allow(certURL string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now := time.Now(); now.Sub(l.start) >= l.window {
+		l.start = now
+		l.total = 0
+		l.byURL = make(map[string]int)
+	}
+
+	if (l.global > 0 && l.total >= l.global) || (l.perURL > 0 && l.byURL[certURL] >= l.perURL) {
+		return false
+	}
+	l.total++
+	l.byURL[certURL]++
+
+	return true
 }
 
 type RequestValidatorOption func(r *RequestValidator)
 
+// WithRequestValidatorTimeout limits the time spent downloading the Amazon signing certificate, including
+// all retries and the waits between them. The default of 5s leaves time to answer within the 8s the Alexa
+// service waits for a response.
 func WithRequestValidatorTimeout(timeout time.Duration) func(r *RequestValidator) {
 	return func(r *RequestValidator) {
 		r.timeout = timeout
@@ -299,6 +1134,60 @@ func WithInsecureSkipVerify(insecureSkipVerify bool) func(r *RequestValidator) {
 	}
 }
 
+// WithCertFetchRetries sets how often the download of the Amazon signing certificate is retried after
+// a network error or a 5xx response. The wait between attempts starts at base and doubles on every retry.
+// No attempt is started once the timeout set with `WithRequestValidatorTimeout` has passed.
+func WithCertFetchRetries(n int, base time.Duration) func(r *RequestValidator) {
+	return func(r *RequestValidator) {
+		r.certFetchRetries = n
+		r.certFetchBackoff = base
+	}
+}
+
+// WithPinnedCertSPKI restricts the accepted Amazon signing certificates to those whose public key matches
+// one of the pins, in addition to all other checks. A pin is the base64 encoded SHA-256 hash of the
+// certificate's DER encoded SubjectPublicKeyInfo, e.g. as printed by:
+//
+//	openssl x509 -pubkey -noout -in cert.pem | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+//
+// Pins have to be updated before Amazon rotates its signing key, or all requests will be rejected.
+func WithPinnedCertSPKI(pins []string) func(r *RequestValidator) {
+	return func(r *RequestValidator) {
+		r.pinnedSPKI = make(map[string]bool, len(pins))
+		for _, pin := range pins {
+			r.pinnedSPKI[pin] = true
+		}
+	}
+}
+
+// WithCertInspector sets a callback that is called with every signing certificate after it was parsed,
+// before any of the checks on it. This allows logging the fingerprint and expiry of the certificates to
+// audit their rotation. The callback must not modify the certificate.
+func WithCertInspector(inspect func(*x509.Certificate)) func(r *RequestValidator) {
+	return func(r *RequestValidator) {
+		r.certInspector = inspect
+	}
+}
+
+// WithAllowedCertNames sets the host names of which the signing certificate has to be valid for at least
+// one, "echo-api.amazon.com" by default. Wildcard names in the certificate are matched as in TLS, so
+// a certificate for "*.amazon.com" is valid for "echo-api.amazon.com".
+func WithAllowedCertNames(names []string) func(r *RequestValidator) {
+	return func(r *RequestValidator) {
+		r.certNames = names
+	}
+}
+
+// WithCertFetchRateLimit limits the downloads of signing certificates to global downloads and perURL
+// downloads of the same URL within every window. A limit of zero disables the respective check. Requests
+// needing a download beyond the limits are rejected with ErrCertDownload, so requests carrying many different certificate
+// URLs can't make the server download certificates in bulk. Retries of a failed download aren't counted.
+func WithCertFetchRateLimit(global, perURL int, window time.Duration) func(r *RequestValidator) {
+	return func(r *RequestValidator) {
+		r.certFetchLimiter = &certFetchLimiter{window: window, global: global, perURL: perURL}
+	}
+}
+
 func NewRequestValidator(options ...RequestValidatorOption) (RequestValidator, error) {
 	var certPool *x509.CertPool
 	var err error
@@ -315,7 +1204,10 @@ func NewRequestValidator(options ...RequestValidatorOption) (RequestValidator, e
 	}
 
 	r := RequestValidator{
-		timeout: time.Second * 5,
+		timeout:          time.Second * 5,
+		certFetchRetries: 2,
+		certFetchBackoff: time.Millisecond * 100,
+		certFetches:      &certFetchGroup{},
 	}
 	for _, option := range options {
 		option(&r)
@@ -323,6 +1215,7 @@ func NewRequestValidator(options ...RequestValidatorOption) (RequestValidator, e
 
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{RootCAs: certPool, InsecureSkipVerify: r.insecureSkipVerify},
+		IdleConnTimeout: 90 * time.Second, // Validators that are never closed don't keep connections forever.
 	}
 
 	if r.client == nil {
@@ -335,113 +1228,305 @@ func NewRequestValidator(options ...RequestValidatorOption) (RequestValidator, e
 	return r, nil
 }
 
-// Run all mandatory Amazon security checks on the request.
-func (r RequestValidator) validateRequest(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
-	devFlag := req.URL.Query().Get("_dev")
-	isDev := devFlag != ""
-	if !isDev && !r.IsValidAlexaRequest(w, req) {
-		log.Println("Request invalid")
-		return
+// Close releases the resources held by the validator, including the idle connections kept open
+// for downloading the Amazon signing certificates. The validator must not be used afterwards.
+func (r RequestValidator) Close() error {
+	if r.client != nil {
+		r.client.CloseIdleConnections()
 	}
-	next(w, req)
+
+	return nil
 }
 
+// Run all mandatory Amazon security checks on the request. If the soft failure speech is set, invalid
+// requests are answered with a valid response speaking it, see `WithSoftValidationFailure`.
+func validateRequest(v Validator, softFailureSpeech string) negroni.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+		devFlag := req.URL.Query().Get("_dev")
+		isDev := devFlag != ""
+		if !isDev {
+			if err := v.Validate(req); err != nil {
+				if softFailureSpeech != "" {
+					log.Println("Request invalid, answering with speech:", err)
+					WriteEcho(w, NewEchoResponse().OutputSpeech(softFailureSpeech))
+					return
+				}
+
+				writeValidationError(w, err, echoError)
+				log.Println("Request invalid")
+				return
+			}
+		}
+		next(w, req)
+	}
+}
+
+func writeValidationError(w http.ResponseWriter, err error, writeError func(http.ResponseWriter, string, string, int)) {
+	if errors.Is(err, ErrBodyRead) {
+		writeError(w, err.Error(), "Internal Error", 500)
+	} else {
+		writeError(w, err.Error(), "Not Authorized", 401)
+	}
+}
+
+// closeValidator releases the resources of validators that hold any.
+func closeValidator(v Validator) {
+	if closer, ok := v.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// Headers the Alexa service uses to sign a request. Go canonicalizes incoming header names, so
+// `SignatureCertChainUrl` is received as `Signaturecertchainurl`; lookups of both headers are case-insensitive.
+const (
+	SignatureCertChainURLHeader = "SignatureCertChainUrl"
+	SignatureHeader             = "Signature"
+)
+
+// Errors returned by `RequestValidator.Validate` for the different steps of the request validation.
+var (
+	ErrInvalidCertURL    = errors.New("invalid cert URL")
+	ErrCertDownload      = errors.New("could not fetch Amazon certificate")
+	ErrCertParse         = errors.New("failed to parse Amazon certificate")
+	ErrCertExpired       = errors.New("amazon certificate expired")
+	ErrCertInvalidName   = errors.New("amazon certificate invalid")
+	ErrBodyRead          = errors.New("could not read request body")
+	ErrSignatureMismatch = errors.New("signature match failed")
+	ErrCertPinMismatch   = errors.New("amazon certificate does not match pinned public keys")
+)
+
 // IsValidAlexaRequest handles all the necessary steps to validate that an incoming http.Request has actually come from
 // the Alexa service. If an error occurs during the validation process, an http.Error will be written to the provided http.ResponseWriter.
 // The required steps for request validation can be found on this page:
 // --insecure-skip-verify flag will disable all validations
 // https://developer.amazon.com/public/solutions/alexa/alexa-skills-kit/docs/developing-an-alexa-skill-as-a-web-service#hosting-a-custom-skill-as-a-web-service
 func (r RequestValidator) IsValidAlexaRequest(w http.ResponseWriter, request *http.Request) bool {
+	if err := r.Validate(request); err != nil {
+		writeValidationError(w, err, HTTPError)
+		return false
+	}
+
+	return true
+}
+
+// Validate runs the same checks as `IsValidAlexaRequest` but leaves writing the response to the caller.
+// The returned error wraps one of the `Err*` values of this package describing the failed check.
+// The certificate URL is read from the `SignatureCertChainUrl` header and the signature from the `Signature` header.
+func (r RequestValidator) Validate(request *http.Request) error {
 	if r.insecureSkipVerify {
-		return true
+		return nil
 	}
-	certURL := request.Header.Get("SignatureCertChainUrl")
+	certURL := headerValue(request.Header, SignatureCertChainURLHeader)
 
 	// Verify certificate URL
 	if !verifyCertURL(certURL) {
-		HTTPError(w, "Invalid cert URL: "+certURL, "Not Authorized", 401)
-		return false
+		return fmt.Errorf("%w: %s", ErrInvalidCertURL, certURL)
 	}
 
 	// Fetch certificate data
 	certContents, err := r.readCert(certURL)
 	if err != nil {
-		HTTPError(w, err.Error(), "Not Authorized", 401)
-		return false
+		return fmt.Errorf("%w: %v", ErrCertDownload, err)
 	}
 
 	// Decode certificate data
 	block, _ := pem.Decode(certContents)
 	if block == nil {
-		HTTPError(w, "Failed to parse certificate PEM.", "Not Authorized", 401)
-		return false
+		return fmt.Errorf("%w: no PEM data found", ErrCertParse)
 	}
 
 	cert, err := x509.ParseCertificate(block.Bytes)
 	if err != nil {
-		HTTPError(w, err.Error(), "Not Authorized", 401)
-		return false
+		return fmt.Errorf("%w: %v", ErrCertParse, err)
+	}
+
+	if r.certInspector != nil {
+		r.certInspector(cert)
 	}
 
 	// Check the certificate date
 	if time.Now().Unix() < cert.NotBefore.Unix() || time.Now().Unix() > cert.NotAfter.Unix() {
-		HTTPError(w, "Amazon certificate expired.", "Not Authorized", 401)
-		return false
+		return ErrCertExpired
 	}
 
 	// Check the certificate alternate names
+	certNames := r.certNames
+	if len(certNames) == 0 {
+		certNames = []string{"echo-api.amazon.com"}
+	}
 	foundName := false
-	for _, altName := range cert.Subject.Names {
-		if altName.Value == "echo-api.amazon.com" {
+	for _, name := range certNames {
+		if cert.VerifyHostname(name) == nil || cert.Subject.CommonName == name {
 			foundName = true
 		}
 	}
 
 	if !foundName {
-		HTTPError(w, "Amazon certificate invalid.", "Not Authorized", 401)
-		return false
+		return ErrCertInvalidName
+	}
+
+	// Check the public key against the pins
+	if len(r.pinnedSPKI) > 0 {
+		spki := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		if !r.pinnedSPKI[base64.StdEncoding.EncodeToString(spki[:])] {
+			return ErrCertPinMismatch
+		}
 	}
 
 	// Verify the key
-	publicKey := cert.PublicKey
-	encryptedSig, _ := base64.StdEncoding.DecodeString(request.Header.Get("Signature"))
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: unexpected public key type", ErrCertParse)
+	}
+	encryptedSig, _ := base64.StdEncoding.DecodeString(headerValue(request.Header, SignatureHeader))
 
 	// Make the request body SHA1 and verify the request with the public key
-	var bodyBuf bytes.Buffer
-	hash := sha1.New()
-	_, err = io.Copy(hash, io.TeeReader(request.Body, &bodyBuf))
+	body, err := requestBody(request)
 	if err != nil {
-		HTTPError(w, err.Error(), "Internal Error", 500)
-		return false
+		return fmt.Errorf("%w: %v", ErrBodyRead, err)
 	}
-	//log.Println(bodyBuf.String())
-	request.Body = ioutil.NopCloser(&bodyBuf)
+	hash := sha1.Sum(body)
 
-	err = rsa.VerifyPKCS1v15(publicKey.(*rsa.PublicKey), crypto.SHA1, hash.Sum(nil), encryptedSig)
+	err = rsa.VerifyPKCS1v15(publicKey, crypto.SHA1, hash[:], encryptedSig)
 	if err != nil {
-		HTTPError(w, "Signature match failed.", "Not Authorized", 401)
-		return false
+		return ErrSignatureMismatch
 	}
 
-	return true
+	return nil
 }
 
 func (r RequestValidator) readCert(certURL string) ([]byte, error) {
-	cert, err := r.client.Get(certURL)
+	if r.certFetches == nil {
+		return r.downloadCert(certURL)
+	}
+
+	return r.certFetches.do(certURL, func() ([]byte, error) {
+		return r.downloadCert(certURL)
+	})
+}
+
+// Download the certificate, retrying as configured with `WithCertFetchRetries`. All attempts share a
+// single deadline, so a slow certificate host can't hold the request longer than the validator timeout.
+func (r RequestValidator) downloadCert(certURL string) ([]byte, error) {
+	if r.certFetchLimiter != nil && !r.certFetchLimiter.allow(certURL) {
+		return nil, errors.New("too many certificate downloads")
+	}
+
+	ctx := context.Background()
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	var certContents []byte
+	var err error
+	var retry bool
+
+	backoff := r.certFetchBackoff
+	for attempt := 0; ; attempt++ {
+		certContents, retry, err = r.fetchCert(ctx, certURL)
+		if err == nil || !retry || attempt >= r.certFetchRetries {
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%v, giving up after %d attempts: %w", err, attempt+1, ctx.Err())
+		}
+		backoff *= 2
+	}
+
+	return certContents, err
+}
+
+// certFetchGroup coalesces concurrent downloads of the same certificate, so a burst of requests
+// signed with the same certificate causes a single download.
+type certFetchGroup struct {
+	mu    sync.Mutex
+	calls map[string]*certFetchCall
+}
+
+type certFetchCall struct {
+	done         chan struct{}
+	certContents []byte
+	err          error
+}
+
+// Run fetch for the URL unless a fetch for it is already in flight, in which case its result is returned.
+func (g *certFetchGroup) do(certURL string, fetch func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if call, ok := g.calls[certURL]; ok {
+		g.mu.Unlock()
+		<-call.done
+		return call.certContents, call.err
+	}
+
+	call := &certFetchCall{done: make(chan struct{})}
+	if g.calls == nil {
+		g.calls = make(map[string]*certFetchCall)
+	}
+	g.calls[certURL] = call
+	g.mu.Unlock()
+
+	call.certContents, call.err = fetch()
+	close(call.done)
+
+	g.mu.Lock()
+	delete(g.calls, certURL)
+	g.mu.Unlock()
+
+	return call.certContents, call.err
+}
+
+// fetchCert does a single download of the certificate. The returned flag indicates whether the
+// download failed in a way that is worth retrying.
+func (r RequestValidator) fetchCert(ctx context.Context, certURL string) ([]byte, bool, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not download Amazon cert file: %w", err)
+	}
+
+	cert, err := r.client.Do(request)
 	if err != nil {
-		return nil, fmt.Errorf("could not download Amazon cert file: %w", err)
+		return nil, ctx.Err() == nil, fmt.Errorf("could not download Amazon cert file: %w", err)
 	}
 	defer cert.Body.Close()
+
+	if cert.StatusCode != http.StatusOK {
+		return nil, cert.StatusCode >= 500, fmt.Errorf("could not download Amazon cert file: status %d", cert.StatusCode)
+	}
+
 	certContents, err := ioutil.ReadAll(cert.Body)
 	if err != nil {
-		return nil, fmt.Errorf("could not read Amazon cert file: %w", err)
+		return nil, true, fmt.Errorf("could not read Amazon cert file: %w", err)
+	}
+
+	return certContents, false, nil
+}
+
+// headerValue returns the first value of the named header. Unlike `http.Header.Get` it also finds
+// headers that were added to the map without canonicalizing their name, e.g. by a proxy or in tests.
+func headerValue(h http.Header, name string) string {
+	if value := h.Get(name); value != "" {
+		return value
 	}
 
-	return certContents, nil
+	for key, values := range h {
+		if strings.EqualFold(key, name) && len(values) > 0 {
+			return values[0]
+		}
+	}
+
+	return ""
 }
 
 func verifyCertURL(path string) bool {
-	link, _ := url.Parse(path)
+	link, err := url.Parse(path)
+	if err != nil {
+		return false
+	}
 
 	if link.Scheme != "https" {
 		return false
@@ -0,0 +1,1879 @@
+package skillserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testAppID = "amzn1.ask.skill.test"
+
+// Returns the JSON of an unsigned echo request of the given type for the test application.
+func testRequestBody(requestType string) string {
+	return testRequestBodyWith(requestType, "")
+}
+
+// Returns the JSON of an unsigned echo request like `testRequestBody`, with the additional fields
+// (e.g. `"reason": "USER_INITIATED"`) added to the request object.
+func testRequestBodyWith(requestType, requestFields string) string {
+	if requestFields != "" {
+		requestFields = ", " + requestFields
+	}
+
+	return fmt.Sprintf(`{
+		"version": "1.0",
+		"session": {"new": true, "sessionId": "session", "application": {"applicationId": %q}},
+		"context": {"System": {"application": {"applicationId": %q}, "device": {"deviceId": "device"}}},
+		"request": {"type": %q, "requestId": "request", "timestamp": %q, "locale": "en-US"%s}
+	}`, testAppID, testAppID, requestType, time.Now().UTC().Format(time.RFC3339), requestFields)
+}
+
+// Posts the body to the echo endpoint of the test server.
+func postTestEcho(h http.Handler, body string) *httptest.ResponseRecorder {
+	return serveTest(h, httptest.NewRequest("POST", "/echo/test", strings.NewReader(body)))
+}
+
+// Returns the apps of a test server: a skill speaking "Hello" on launch at /echo/test and an
+// StdApplication at /url answering with the external URL of /callback.
+func testApps() map[string]interface{} {
+	return map[string]interface{}{
+		"/echo/test": EchoApplication{
+			AppID: testAppID,
+			OnLaunch: func(req *EchoRequest, resp *EchoResponse) {
+				resp.OutputSpeech("Hello")
+			},
+		},
+		"/url": StdApplication{
+			Methods: "GET",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, ExternalURL(r, "/callback"))
+			},
+		},
+	}
+}
+
+// Builds the test server, failing the test if the apps can't be initialized.
+func testHandler(t *testing.T, options ...Option) http.Handler {
+	t.Helper()
+
+	h, err := Handler(testApps(), options...)
+	if err != nil {
+		t.Fatalf("Handler() error = %v", err)
+	}
+
+	return h
+}
+
+// Builds a test server serving only the application at /echo/test, with request validation disabled.
+func testAppHandler(t *testing.T, app EchoApplication, options ...Option) http.Handler {
+	t.Helper()
+
+	if app.AppID == "" {
+		app.AppID = testAppID
+	}
+	h, err := Handler(map[string]interface{}{"/echo/test": app}, append([]Option{WithValidator(NoopValidator())}, options...)...)
+	if err != nil {
+		t.Fatalf("Handler() error = %v", err)
+	}
+
+	return h
+}
+
+func serveTest(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+// Parses a raw HTTP/1.1 request as a server would, e.g. to send a request line in absolute form.
+func readTestRequest(t *testing.T, raw string) *http.Request {
+	t.Helper()
+
+	r, err := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatalf("ReadRequest() error = %v", err)
+	}
+
+	return r
+}
+
+func TestExternalURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted bool
+		request func(t *testing.T) *http.Request
+		want    string
+	}{
+		{
+			name:    "forwarded headers ignored by default",
+			trusted: false,
+			request: func(t *testing.T) *http.Request {
+				r := httptest.NewRequest("GET", "http://internal:8080/url", nil)
+				r.Header.Set("X-Forwarded-Proto", "https")
+				r.Header.Set("X-Forwarded-Host", "skill.example.com")
+				return r
+			},
+			want: "http://internal:8080/callback",
+		},
+		{
+			name:    "forwarded headers honored when trusted",
+			trusted: true,
+			request: func(t *testing.T) *http.Request {
+				r := httptest.NewRequest("GET", "http://internal:8080/url", nil)
+				r.Header.Set("X-Forwarded-Proto", "https")
+				r.Header.Set("X-Forwarded-Host", "skill.example.com")
+				return r
+			},
+			want: "https://skill.example.com/callback",
+		},
+		{
+			name:    "absolute request line doesn't set the scheme",
+			trusted: false,
+			request: func(t *testing.T) *http.Request {
+				return readTestRequest(t, "GET https://internal:8080/url HTTP/1.1\r\nHost: internal:8080\r\n\r\n")
+			},
+			want: "http://internal:8080/callback",
+		},
+		{
+			name:    "tls",
+			trusted: false,
+			request: func(t *testing.T) *http.Request {
+				return httptest.NewRequest("GET", "https://internal:8443/url", nil)
+			},
+			want: "https://internal:8443/callback",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := testHandler(t, WithTrustedProxy(tt.trusted))
+
+			w := serveTest(h, tt.request(t))
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("ExternalURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireHTTPS(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		request func(t *testing.T, body string) *http.Request
+		want    int
+	}{
+		{
+			name:    "http forwarded by trusted proxy rejected",
+			options: []Option{WithTrustedProxy(true)},
+			request: func(t *testing.T, body string) *http.Request {
+				r := httptest.NewRequest("POST", "http://internal/echo/test", strings.NewReader(body))
+				r.Header.Set("X-Forwarded-Proto", "http")
+				return r
+			},
+			want: http.StatusBadRequest,
+		},
+		{
+			name:    "https forwarded by trusted proxy accepted",
+			options: []Option{WithTrustedProxy(true)},
+			request: func(t *testing.T, body string) *http.Request {
+				r := httptest.NewRequest("POST", "http://internal/echo/test", strings.NewReader(body))
+				r.Header.Set("X-Forwarded-Proto", "https")
+				return r
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: "forwarded https ignored without trusted proxy",
+			request: func(t *testing.T, body string) *http.Request {
+				r := httptest.NewRequest("POST", "http://internal/echo/test", strings.NewReader(body))
+				r.Header.Set("X-Forwarded-Proto", "https")
+				return r
+			},
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "absolute https request line over plain http rejected",
+			request: func(t *testing.T, body string) *http.Request {
+				return readTestRequest(t, fmt.Sprintf("POST https://internal/echo/test HTTP/1.1\r\nHost: internal\r\nContent-Length: %d\r\n\r\n%s", len(body), body))
+			},
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "tls accepted",
+			request: func(t *testing.T, body string) *http.Request {
+				return httptest.NewRequest("POST", "https://internal/echo/test", strings.NewReader(body))
+			},
+			want: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]Option{WithValidator(NoopValidator()), WithRequireHTTPS(true)}, tt.options...)
+			h := testHandler(t, options...)
+
+			w := serveTest(h, tt.request(t, testRequestBody("LaunchRequest")))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d, body %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestStrictJSON(t *testing.T) {
+	sessionWithScopes := strings.Replace(testRequestBody("LaunchRequest"), `"sessionId": "session",`,
+		`"sessionId": "session", "user": {"userId": "user", "permissions": {"scopes": {"alexa::devices:all:geolocation:read": {"status": "GRANTED"}}}},`, 1)
+
+	tests := []struct {
+		name   string
+		strict bool
+		body   string
+		want   int
+	}{
+		{"launch request", true, testRequestBodyWith("LaunchRequest", `"shouldLinkResultBeReturned": false`), http.StatusOK},
+		{"session ended with error", true, testRequestBodyWith("SessionEndedRequest", `"reason": "ERROR", "error": {"type": "INVALID_RESPONSE", "message": "bad"}`), http.StatusOK},
+		{"session resumed with cause", true, testRequestBodyWith("SessionResumedRequest", `"cause": {"type": "ConnectionCompleted", "token": "t", "status": {"code": "200"}, "result": {}}`), http.StatusOK},
+		{"permission scopes", true, sessionWithScopes, http.StatusOK},
+		{"unknown field rejected", true, testRequestBodyWith("LaunchRequest", `"unexpected": true`), http.StatusBadRequest},
+		{"unknown field accepted when lenient", false, testRequestBodyWith("LaunchRequest", `"unexpected": true`), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apps := testApps()
+			apps["/echo/test"] = EchoApplication{
+				AppID: testAppID,
+				OnUnknownRequest: func(requestType string, req *EchoRequest, resp *EchoResponse) {
+					resp.OutputSpeech("Hello")
+				},
+			}
+			h, err := Handler(apps, WithValidator(NoopValidator()), WithStrictJSON(tt.strict))
+			if err != nil {
+				t.Fatalf("Handler() error = %v", err)
+			}
+
+			w := postTestEcho(h, tt.body)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d, body %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestOnDialogAPIInvoked(t *testing.T) {
+	apps := map[string]interface{}{
+		"/echo/test": EchoApplication{
+			AppID: testAppID,
+			OnDialogAPIInvoked: func(req *EchoRequest, resp *EchoResponse) {
+				resp.DialogAPIResponse(json.RawMessage(`{"name":"` + req.GetAPIRequestName() + `"}`))
+			},
+		},
+	}
+	h, err := Handler(apps, WithValidator(NoopValidator()))
+	if err != nil {
+		t.Fatalf("Handler() error = %v", err)
+	}
+
+	w := postTestEcho(h, testRequestBodyWith("Dialog.API.Invoked", `"apiRequest": {"name": "GetWeather", "arguments": {}}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"apiResponse":{"name":"GetWeather"}`) {
+		t.Errorf("body = %s, want the api response", w.Body.String())
+	}
+}
+
+func TestOnUnknownRequest(t *testing.T) {
+	var gotType string
+	h := testAppHandler(t, EchoApplication{
+		OnUnknownRequest: func(requestType string, req *EchoRequest, resp *EchoResponse) {
+			gotType = requestType
+			resp.OutputSpeech("Not yet.")
+		},
+	})
+
+	w := postTestEcho(h, testRequestBody("Made.Up.Request"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if gotType != "Made.Up.Request" {
+		t.Errorf("OnUnknownRequest called with %q, want %q", gotType, "Made.Up.Request")
+	}
+	if !strings.Contains(w.Body.String(), `"text":"Not yet."`) {
+		t.Errorf("body = %s, want the speech of the unknown request handler", w.Body.String())
+	}
+}
+
+var (
+	testKeyOnce sync.Once
+	testKey     *rsa.PrivateKey
+)
+
+// Returns the RSA key signing the test certificates and requests, generated once for all tests.
+func testSigningKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	testKeyOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("GenerateKey() error = %v", err)
+		}
+		testKey = key
+	})
+	if testKey == nil {
+		t.Fatal("no test signing key")
+	}
+
+	return testKey
+}
+
+// Returns a PEM encoded self-signed certificate for the test signing key, valid for the DNS names
+// from notBefore to notAfter.
+func testCertPEM(t *testing.T, dnsNames []string, notBefore, notAfter time.Time) []byte {
+	t.Helper()
+
+	key := testSigningKey(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test Signing Certificate"},
+		DNSNames:     dnsNames,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// Returns a currently valid certificate for echo-api.amazon.com.
+func testValidCertPEM(t *testing.T) []byte {
+	return testCertPEM(t, []string{"echo-api.amazon.com"}, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+}
+
+// Returns the Signature header value for the body, signed with the test signing key.
+func testSignature(t *testing.T, body string) string {
+	t.Helper()
+
+	hash := sha1.Sum([]byte(body))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, testSigningKey(t), crypto.SHA1, hash[:])
+	if err != nil {
+		t.Fatalf("SignPKCS1v15() error = %v", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+const testCertURL = "https://s3.amazonaws.com/echo.api/echo-api-cert.pem"
+
+// Returns an echo request signed like by the Alexa service, referencing the certificate at the URL.
+func testSignedRequest(t *testing.T, certURL, body string) *http.Request {
+	t.Helper()
+
+	r := httptest.NewRequest("POST", "/echo/test", strings.NewReader(body))
+	r.Header.Set("SignatureCertChainUrl", certURL)
+	r.Header.Set("Signature", testSignature(t, body))
+
+	return r
+}
+
+// certTransport serves certificates by URL in place of the network and counts the downloads.
+type certTransport struct {
+	mu        sync.Mutex
+	certs     map[string][]byte
+	delay     time.Duration // Waited before answering unless the request is canceled, to let concurrent downloads overlap.
+	failures  int           // Number of downloads answered with a 503 before the certificates are served.
+	downloads map[string]int
+}
+
+func newCertTransport(certs map[string][]byte) *certTransport {
+	return &certTransport{certs: certs, downloads: make(map[string]int)}
+}
+
+func (c *certTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.downloads[r.URL.String()]++
+	cert, ok := c.certs[r.URL.String()]
+	fail := c.failures > 0
+	if fail {
+		c.failures--
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-time.After(c.delay):
+	case <-r.Context().Done():
+		return nil, r.Context().Err()
+	}
+
+	resp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: ioutil.NopCloser(bytes.NewReader(cert)), Request: r}
+	if fail {
+		resp.StatusCode = http.StatusServiceUnavailable
+		resp.Body = ioutil.NopCloser(strings.NewReader("unavailable"))
+	} else if !ok {
+		resp.StatusCode = http.StatusNotFound
+		resp.Body = ioutil.NopCloser(strings.NewReader("not found"))
+	}
+
+	return resp, nil
+}
+
+// Returns the total number of downloads.
+func (c *certTransport) total() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total := 0
+	for _, n := range c.downloads {
+		total += n
+	}
+
+	return total
+}
+
+// Returns a RequestValidator downloading the certificates from the transport.
+func testRequestValidator(t *testing.T, transport http.RoundTripper, options ...RequestValidatorOption) RequestValidator {
+	t.Helper()
+
+	v, err := NewRequestValidator(options...)
+	if err != nil {
+		t.Fatalf("NewRequestValidator() error = %v", err)
+	}
+	v.client = &http.Client{Transport: transport}
+	v.certFetchBackoff = time.Millisecond
+
+	return v
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestValidateErrors(t *testing.T) {
+	body := testRequestBody("LaunchRequest")
+	expired := testCertPEM(t, []string{"echo-api.amazon.com"}, time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+	wrongName := testCertPEM(t, []string{"example.com"}, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+
+	transport := newCertTransport(map[string][]byte{
+		testCertURL: testValidCertPEM(t),
+		"https://s3.amazonaws.com/echo.api/expired.pem":    expired,
+		"https://s3.amazonaws.com/echo.api/wrong-name.pem": wrongName,
+		"https://s3.amazonaws.com/echo.api/not-a-cert.pem": []byte("not a certificate"),
+	})
+	v := testRequestValidator(t, transport)
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		want    error
+	}{
+		{"valid", func() *http.Request { return testSignedRequest(t, testCertURL, body) }, nil},
+		{"http cert URL", func() *http.Request {
+			return testSignedRequest(t, "http://s3.amazonaws.com/echo.api/echo-api-cert.pem", body)
+		}, ErrInvalidCertURL},
+		{"foreign cert host", func() *http.Request {
+			return testSignedRequest(t, "https://evil.example.com/echo.api/echo-api-cert.pem", body)
+		}, ErrInvalidCertURL},
+		{"unparsable cert URL", func() *http.Request {
+			return testSignedRequest(t, "https://s3.amazonaws.com/%zz", body)
+		}, ErrInvalidCertURL},
+		{"cert path outside echo.api", func() *http.Request {
+			return testSignedRequest(t, "https://s3.amazonaws.com/other/echo-api-cert.pem", body)
+		}, ErrInvalidCertURL},
+		{"missing cert", func() *http.Request {
+			return testSignedRequest(t, "https://s3.amazonaws.com/echo.api/missing.pem", body)
+		}, ErrCertDownload},
+		{"cert not PEM", func() *http.Request {
+			return testSignedRequest(t, "https://s3.amazonaws.com/echo.api/not-a-cert.pem", body)
+		}, ErrCertParse},
+		{"expired cert", func() *http.Request {
+			return testSignedRequest(t, "https://s3.amazonaws.com/echo.api/expired.pem", body)
+		}, ErrCertExpired},
+		{"cert for other name", func() *http.Request {
+			return testSignedRequest(t, "https://s3.amazonaws.com/echo.api/wrong-name.pem", body)
+		}, ErrCertInvalidName},
+		{"tampered body", func() *http.Request {
+			r := testSignedRequest(t, testCertURL, body)
+			r.Body = ioutil.NopCloser(strings.NewReader(strings.Replace(body, "LaunchRequest", "IntentRequest", 1)))
+			return r
+		}, ErrSignatureMismatch},
+		{"unreadable body", func() *http.Request {
+			r := testSignedRequest(t, testCertURL, body)
+			r.Body = ioutil.NopCloser(failingReader{})
+			return r
+		}, ErrBodyRead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Validate(tt.request())
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsValidAlexaRequestStatus(t *testing.T) {
+	body := testRequestBody("LaunchRequest")
+	v := testRequestValidator(t, newCertTransport(map[string][]byte{testCertURL: testValidCertPEM(t)}))
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		valid   bool
+		status  int
+	}{
+		{"valid", func() *http.Request { return testSignedRequest(t, testCertURL, body) }, true, http.StatusOK},
+		{"invalid signature", func() *http.Request {
+			r := testSignedRequest(t, testCertURL, body)
+			r.Header.Set("Signature", "invalid")
+			return r
+		}, false, http.StatusUnauthorized},
+		{"unparsable cert URL", func() *http.Request {
+			return testSignedRequest(t, "https://s3.amazonaws.com/%zz", body)
+		}, false, http.StatusUnauthorized},
+		{"unreadable body", func() *http.Request {
+			r := testSignedRequest(t, testCertURL, body)
+			r.Body = ioutil.NopCloser(failingReader{})
+			return r
+		}, false, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if got := v.IsValidAlexaRequest(w, tt.request()); got != tt.valid {
+				t.Errorf("IsValidAlexaRequest() = %v, want %v", got, tt.valid)
+			}
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestValidateSignatureHeaderCasing(t *testing.T) {
+	body := testRequestBody("LaunchRequest")
+	v := testRequestValidator(t, newCertTransport(map[string][]byte{testCertURL: testValidCertPEM(t)}))
+
+	for _, name := range []string{"SignatureCertChainUrl", "Signaturecertchainurl", "signaturecertchainurl", "SIGNATURECERTCHAINURL"} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/echo/test", strings.NewReader(body))
+			// Set the headers without canonicalizing the names, like a proxy or a test client may.
+			r.Header[name] = []string{testCertURL}
+			r.Header["signature"] = []string{testSignature(t, body)}
+
+			if err := v.Validate(r); err != nil {
+				t.Errorf("Validate() error = %v, want nil", err)
+			}
+		})
+	}
+
+	t.Run("received over the wire", func(t *testing.T) {
+		raw := fmt.Sprintf("POST /echo/test HTTP/1.1\r\nHost: skill\r\nSignatureCertChainUrl: %s\r\nSignature: %s\r\nContent-Length: %d\r\n\r\n%s",
+			testCertURL, testSignature(t, body), len(body), body)
+
+		if err := v.Validate(readTestRequest(t, raw)); err != nil {
+			t.Errorf("Validate() error = %v, want nil", err)
+		}
+	})
+}
+
+// Waits until at most want goroutines are running and returns the number of goroutines then running.
+func waitForGoroutines(want int, timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+	for {
+		n := runtime.NumGoroutine()
+		if n <= want || time.Now().After(deadline) {
+			return n
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRequestValidatorCloseLeavesNoGoroutines(t *testing.T) {
+	cert := testValidCertPEM(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(cert)
+	}))
+	defer server.Close()
+
+	before := runtime.NumGoroutine()
+
+	v := testRequestValidator(t, &http.Transport{
+		// Send the downloads from s3.amazonaws.com to the test server.
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	})
+
+	body := testRequestBody("LaunchRequest")
+	if err := v.Validate(testSignedRequest(t, testCertURL, body)); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if n := runtime.NumGoroutine(); n <= before {
+		t.Fatalf("%d goroutines running after the download, want more than %d for the kept alive connection", n, before)
+	}
+
+	if err := v.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if n := waitForGoroutines(before, 2*time.Second); n > before {
+		t.Errorf("%d goroutines running after Close, want at most %d", n, before)
+	}
+}
+
+func TestNoopValidatorAcceptsUnsignedRequests(t *testing.T) {
+	body := testRequestBody("LaunchRequest")
+
+	w := postTestEcho(testHandler(t, WithValidator(NoopValidator())), body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"text":"Hello"`) {
+		t.Errorf("body = %s, want the launch speech", w.Body.String())
+	}
+
+	// The default validator rejects the same request.
+	if w := postTestEcho(testHandler(t), body); w.Code != http.StatusUnauthorized {
+		t.Errorf("status without NoopValidator = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestEmptyBodyIsRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+	}{
+		{"default validator", nil},
+		{"noop validator", []Option{WithValidator(NoopValidator())}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postTestEcho(testHandler(t, tt.options...), "")
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if got := w.Body.String(); got != `{"error":"Bad Request: empty body"}` {
+				t.Errorf("body = %s, want the empty body error", got)
+			}
+		})
+	}
+}
+
+func TestSignedBodyIsReadOnce(t *testing.T) {
+	v := testRequestValidator(t, newCertTransport(map[string][]byte{testCertURL: testValidCertPEM(t)}))
+	h := testHandler(t, WithValidator(v))
+
+	// The signature is checked and the JSON decoded on the same buffered body.
+	w := serveTest(h, testSignedRequest(t, testCertURL, testRequestBody("LaunchRequest")))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"text":"Hello"`) {
+		t.Errorf("body = %s, want the launch speech", w.Body.String())
+	}
+}
+
+func TestPinnedCertSPKI(t *testing.T) {
+	spki, err := x509.MarshalPKIXPublicKey(&testSigningKey(t).PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() error = %v", err)
+	}
+	hash := sha256.Sum256(spki)
+	pin := base64.StdEncoding.EncodeToString(hash[:])
+	otherPin := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	tests := []struct {
+		name string
+		pins []string
+		want error
+	}{
+		{"matching pin", []string{otherPin, pin}, nil},
+		{"no matching pin", []string{otherPin}, ErrCertPinMismatch},
+	}
+
+	body := testRequestBody("LaunchRequest")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := newCertTransport(map[string][]byte{testCertURL: testValidCertPEM(t)})
+			v := testRequestValidator(t, transport, WithPinnedCertSPKI(tt.pins))
+
+			err := v.Validate(testSignedRequest(t, testCertURL, body))
+			if tt.want == nil && err != nil {
+				t.Errorf("Validate() error = %v, want nil", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowedCertNames(t *testing.T) {
+	const regional = "echo-api.eu-west-1.amazon.com"
+
+	tests := []struct {
+		name     string
+		dnsNames []string
+		allowed  []string
+		want     error
+	}{
+		{"default name", []string{"echo-api.amazon.com"}, nil, nil},
+		{"regional name by default", []string{regional}, nil, ErrCertInvalidName},
+		{"regional name allowed", []string{regional}, []string{"echo-api.amazon.com", regional}, nil},
+		{"regional name not in allowlist", []string{regional}, []string{"echo-api.us-east-1.amazon.com"}, ErrCertInvalidName},
+		{"wildcard name by default", []string{"*.amazon.com"}, nil, nil},
+		{"regional wildcard name allowed", []string{"*.eu-west-1.amazon.com"}, []string{regional}, nil},
+		{"wildcard name covers a single label", []string{"*.amazon.com"}, []string{regional}, ErrCertInvalidName},
+		{"wildcard name of another domain", []string{"*.example.com"}, nil, ErrCertInvalidName},
+	}
+
+	body := testRequestBody("LaunchRequest")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := testCertPEM(t, tt.dnsNames, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+			var options []RequestValidatorOption
+			if tt.allowed != nil {
+				options = append(options, WithAllowedCertNames(tt.allowed))
+			}
+			v := testRequestValidator(t, newCertTransport(map[string][]byte{testCertURL: cert}), options...)
+
+			err := v.Validate(testSignedRequest(t, testCertURL, body))
+			if tt.want == nil && err != nil {
+				t.Errorf("Validate() error = %v, want nil", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCertInspector(t *testing.T) {
+	var inspected []*x509.Certificate
+	transport := newCertTransport(map[string][]byte{testCertURL: testValidCertPEM(t)})
+	v := testRequestValidator(t, transport, WithCertInspector(func(cert *x509.Certificate) {
+		inspected = append(inspected, cert)
+	}))
+
+	if err := v.Validate(testSignedRequest(t, testCertURL, testRequestBody("LaunchRequest"))); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if len(inspected) != 1 {
+		t.Fatalf("inspector called %d times, want once", len(inspected))
+	}
+	cert := inspected[0]
+	if cert.Subject.CommonName != "Test Signing Certificate" || !reflect.DeepEqual(cert.DNSNames, []string{"echo-api.amazon.com"}) {
+		t.Errorf("inspected certificate = %v %v, want the signing certificate", cert.Subject, cert.DNSNames)
+	}
+	if key, ok := cert.PublicKey.(*rsa.PublicKey); !ok || key.N.Cmp(testSigningKey(t).N) != 0 {
+		t.Error("inspected certificate doesn't hold the test signing key")
+	}
+}
+
+// Returns a certificate URL that passes the URL checks, distinct for every n.
+func testCertURLN(n int) string {
+	return fmt.Sprintf("https://s3.amazonaws.com/echo.api/echo-api-cert-%d.pem", n)
+}
+
+func TestCertFetchRateLimit(t *testing.T) {
+	cert := testValidCertPEM(t)
+	certs := make(map[string][]byte)
+	for i := 0; i < 10; i++ {
+		certs[testCertURLN(i)] = cert
+	}
+	body := testRequestBody("LaunchRequest")
+
+	t.Run("global", func(t *testing.T) {
+		transport := newCertTransport(certs)
+		v := testRequestValidator(t, transport, WithCertFetchRateLimit(3, 0, time.Hour))
+
+		accepted := 0
+		for i := 0; i < 10; i++ {
+			err := v.Validate(testSignedRequest(t, testCertURLN(i), body))
+			if err == nil {
+				accepted++
+			} else if !errors.Is(err, ErrCertDownload) {
+				t.Errorf("Validate() error = %v, want %v", err, ErrCertDownload)
+			}
+		}
+
+		if accepted != 3 {
+			t.Errorf("%d requests accepted, want 3", accepted)
+		}
+		if n := transport.total(); n != 3 {
+			t.Errorf("%d downloads, want 3", n)
+		}
+	})
+
+	t.Run("per URL", func(t *testing.T) {
+		transport := newCertTransport(certs)
+		v := testRequestValidator(t, transport, WithCertFetchRateLimit(0, 2, time.Hour))
+
+		for i := 0; i < 3; i++ {
+			err := v.Validate(testSignedRequest(t, testCertURLN(0), body))
+			if i < 2 && err != nil {
+				t.Errorf("Validate() #%d error = %v, want nil", i, err)
+			}
+			if i == 2 && !errors.Is(err, ErrCertDownload) {
+				t.Errorf("Validate() #%d error = %v, want %v", i, err, ErrCertDownload)
+			}
+		}
+
+		// Other URLs have their own budget.
+		if err := v.Validate(testSignedRequest(t, testCertURLN(1), body)); err != nil {
+			t.Errorf("Validate() of another URL error = %v, want nil", err)
+		}
+		if n := transport.total(); n != 3 {
+			t.Errorf("%d downloads, want 3", n)
+		}
+	})
+
+	t.Run("window", func(t *testing.T) {
+		transport := newCertTransport(certs)
+		v := testRequestValidator(t, transport, WithCertFetchRateLimit(1, 0, 50*time.Millisecond))
+
+		if err := v.Validate(testSignedRequest(t, testCertURLN(0), body)); err != nil {
+			t.Fatalf("Validate() error = %v, want nil", err)
+		}
+		if err := v.Validate(testSignedRequest(t, testCertURLN(1), body)); !errors.Is(err, ErrCertDownload) {
+			t.Fatalf("Validate() in the same window error = %v, want %v", err, ErrCertDownload)
+		}
+
+		time.Sleep(60 * time.Millisecond)
+		if err := v.Validate(testSignedRequest(t, testCertURLN(1), body)); err != nil {
+			t.Errorf("Validate() in the next window error = %v, want nil", err)
+		}
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		transport := newCertTransport(certs)
+		h := testHandler(t, WithValidator(testRequestValidator(t, transport, WithCertFetchRateLimit(1, 0, time.Hour))))
+
+		if w := serveTest(h, testSignedRequest(t, testCertURLN(0), body)); w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body.String())
+		}
+		if w := serveTest(h, testSignedRequest(t, testCertURLN(1), body)); w.Code != http.StatusUnauthorized {
+			t.Errorf("status beyond the limit = %d, want %d", w.Code, http.StatusUnauthorized)
+		}
+		if n := transport.total(); n != 1 {
+			t.Errorf("%d downloads, want 1", n)
+		}
+	})
+}
+
+func TestConcurrentCertDownloadsAreCoalesced(t *testing.T) {
+	transport := newCertTransport(map[string][]byte{testCertURL: testValidCertPEM(t)})
+	transport.delay = 50 * time.Millisecond
+	v := testRequestValidator(t, transport)
+
+	// Sign before starting the goroutines, so they all hit the validator at about the same time.
+	const n = 50
+	body := testRequestBody("LaunchRequest")
+	requests := make([]*http.Request, n)
+	for i := range requests {
+		requests[i] = testSignedRequest(t, testCertURL, body)
+	}
+
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for _, r := range requests {
+		wg.Add(1)
+		go func(r *http.Request) {
+			defer wg.Done()
+			errs <- v.Validate(r)
+		}(r)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Validate() error = %v, want nil", err)
+		}
+	}
+	if got := transport.total(); got != 1 {
+		t.Errorf("%d downloads for %d concurrent requests, want 1", got, n)
+	}
+}
+
+func TestSoftValidationFailure(t *testing.T) {
+	const speech = "Sorry, this request could not be verified."
+	body := testRequestBody("LaunchRequest")
+
+	badSignature := testSignedRequest(t, testCertURL, body)
+	badSignature.Header.Set("Signature", testSignature(t, body+" "))
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		want    string
+	}{
+		{"unsigned", httptest.NewRequest("POST", "/echo/test", strings.NewReader(body)), speech},
+		{"wrong signature", badSignature, speech},
+		{"valid signature", testSignedRequest(t, testCertURL, body), "Hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := testRequestValidator(t, newCertTransport(map[string][]byte{testCertURL: testValidCertPEM(t)}))
+			h := testHandler(t, WithValidator(v), WithSoftValidationFailure(speech))
+
+			w := serveTest(h, tt.request)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body.String())
+			}
+			if want := fmt.Sprintf(`"text":%q`, tt.want); !strings.Contains(w.Body.String(), want) {
+				t.Errorf("body = %s, want the speech %q", w.Body.String(), tt.want)
+			}
+		})
+	}
+
+	// The default validator answers an unsigned request with the speech rather than a 401.
+	w := postTestEcho(testHandler(t, WithSoftValidationFailure(speech)), body)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), speech) {
+		t.Errorf("unsigned request with the default validator: status = %d, body %s, want %d and the speech", w.Code, w.Body.String(), http.StatusOK)
+	}
+}
+
+func TestCertFetchRetries(t *testing.T) {
+	certs := map[string][]byte{testCertURL: testValidCertPEM(t)}
+
+	tests := []struct {
+		name          string
+		certURL       string
+		failures      int
+		retries       int
+		want          error
+		wantDownloads int
+	}{
+		{"succeeds after a failure", testCertURL, 1, 2, nil, 2},
+		{"retries exhausted", testCertURL, 3, 2, ErrCertDownload, 3},
+		{"retries disabled", testCertURL, 1, 0, ErrCertDownload, 1},
+		{"client errors aren't retried", testCertURLN(1), 0, 2, ErrCertDownload, 1},
+	}
+
+	body := testRequestBody("LaunchRequest")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := newCertTransport(certs)
+			transport.failures = tt.failures
+			v := testRequestValidator(t, transport, WithCertFetchRetries(tt.retries, time.Millisecond))
+
+			err := v.Validate(testSignedRequest(t, tt.certURL, body))
+			if tt.want == nil && err != nil {
+				t.Errorf("Validate() error = %v, want nil", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.want)
+			}
+			if n := transport.total(); n != tt.wantDownloads {
+				t.Errorf("%d downloads, want %d", n, tt.wantDownloads)
+			}
+		})
+	}
+}
+
+func TestCertFetchRetriesBackOff(t *testing.T) {
+	transport := newCertTransport(map[string][]byte{testCertURL: testValidCertPEM(t)})
+	transport.failures = 2
+	v := testRequestValidator(t, transport)
+	v.certFetchBackoff = 20 * time.Millisecond
+
+	start := time.Now()
+	if err := v.Validate(testSignedRequest(t, testCertURL, testRequestBody("LaunchRequest"))); err != nil {
+		t.Fatalf("Validate() error = %v, want nil", err)
+	}
+
+	// The waits double: 20ms after the first failure and 40ms after the second one.
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("Validate() took %v, want at least 60ms of backoff", elapsed)
+	}
+}
+
+func TestCertFetchRetriesShareTimeout(t *testing.T) {
+	transport := newCertTransport(map[string][]byte{testCertURL: testValidCertPEM(t)})
+	transport.failures = 100
+	transport.delay = 100 * time.Millisecond
+	v := testRequestValidator(t, transport, WithRequestValidatorTimeout(250*time.Millisecond), WithCertFetchRetries(10, time.Millisecond))
+
+	start := time.Now()
+	err := v.Validate(testSignedRequest(t, testCertURL, testRequestBody("LaunchRequest")))
+	if !errors.Is(err, ErrCertDownload) {
+		t.Errorf("Validate() error = %v, want %v", err, ErrCertDownload)
+	}
+
+	// Without a shared deadline the 11 attempts would take more than a second.
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Validate() took %v, want the download to give up after the 250ms timeout", elapsed)
+	}
+	if downloads := transport.downloads[testCertURL]; downloads > 3 {
+		t.Errorf("downloads = %d, want at most 3 within the timeout", downloads)
+	}
+}
+
+func TestOnSkillEvent(t *testing.T) {
+	var handled []string
+	handle := func(req *EchoRequest, resp *EchoResponse) {
+		handled = append(handled, req.GetRequestType()+" "+string(req.GetSkillEventBody()))
+	}
+	h := testAppHandler(t, EchoApplication{
+		OnSkillEvent: map[string]func(*EchoRequest, *EchoResponse){
+			"AlexaSkillEvent.SkillEnabled":       handle,
+			"AlexaSkillEvent.SkillAccountLinked": handle,
+		},
+	})
+
+	for _, body := range []string{
+		testRequestBodyWith("AlexaSkillEvent.SkillEnabled", ""),
+		testRequestBodyWith("AlexaSkillEvent.SkillAccountLinked", `"body": {"accessToken": "linked"}`),
+		testRequestBodyWith("AlexaSkillEvent.SkillDisabled", ""),
+	} {
+		if w := postTestEcho(h, body); w.Code != http.StatusOK {
+			t.Errorf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body.String())
+		}
+	}
+
+	want := []string{"AlexaSkillEvent.SkillEnabled ", `AlexaSkillEvent.SkillAccountLinked {"accessToken": "linked"}`}
+	if !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled events = %q, want %q", handled, want)
+	}
+}
+
+func TestResponseLimits(t *testing.T) {
+	app := EchoApplication{
+		OnLaunch: func(req *EchoRequest, resp *EchoResponse) {
+			resp.OutputSpeechSSML("<speak>" + strings.Repeat("a", DefaultResponseLimits.MaxSpeechLength) + "</speak>")
+		},
+	}
+	rejecting := DefaultResponseLimits
+	rejecting.Reject = true
+
+	tests := []struct {
+		name   string
+		limits ResponseLimits
+		want   int
+	}{
+		{"flagged only", DefaultResponseLimits, http.StatusOK},
+		{"rejected", rejecting, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postTestEcho(testAppHandler(t, app, WithResponseLimits(tt.limits)), testRequestBody("LaunchRequest"))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipAppIDVerification(t *testing.T) {
+	otherApp := strings.Replace(testRequestBody("LaunchRequest"), testAppID, "amzn1.ask.skill.other", -1)
+
+	tests := []struct {
+		name    string
+		options []Option
+		body    string
+		want    int
+	}{
+		{"matching app ID", nil, testRequestBody("LaunchRequest"), http.StatusOK},
+		{"mismatched app ID", nil, otherApp, http.StatusBadRequest},
+		{"mismatched app ID with verification disabled", []Option{WithSkipAppIDVerification(true)}, otherApp, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postTestEcho(testAppHandler(t, testApps()["/echo/test"].(EchoApplication), tt.options...), tt.body)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d, body %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	// The signature is still checked.
+	if w := postTestEcho(testHandler(t, WithSkipAppIDVerification(true)), otherApp); w.Code != http.StatusUnauthorized {
+		t.Errorf("status of an unsigned request = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	app := EchoApplication{
+		OnLaunch: func(req *EchoRequest, resp *EchoResponse) {
+			panic("handler failed")
+		},
+	}
+
+	tests := []struct {
+		name    string
+		options []Option
+		want    string
+	}{
+		{"default speech", nil, "Sorry, something went wrong. Please try again later."},
+		{"custom speech", []Option{WithRecoverySpeech("Oops.")}, "Oops."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postTestEcho(testAppHandler(t, app, tt.options...), testRequestBody("LaunchRequest"))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/json") {
+				t.Errorf("Content-Type = %q, want JSON", got)
+			}
+
+			var resp EchoResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("could not decode response %s: %v", w.Body.String(), err)
+			}
+			if speech := resp.GetOutputSpeech(); speech == nil || speech.Type != "PlainText" || speech.Text != tt.want {
+				t.Errorf("output speech = %+v, want %q", speech, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidRequestFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []Option
+		want     int
+		wantBody string
+	}{
+		{"default", nil, http.StatusBadRequest, `{"error":"Invalid request."}`},
+		{"custom error", []Option{WithInvalidRequestFallback(http.StatusNotImplemented, "Not supported.")}, http.StatusNotImplemented, `{"error":"Not supported."}`},
+		{"speech", []Option{WithInvalidRequestFallback(http.StatusOK, "Sorry, I can't do that.")}, http.StatusOK, `"text":"Sorry, I can't do that."`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := testAppHandler(t, testApps()["/echo/test"].(EchoApplication), tt.options...)
+
+			w := postTestEcho(h, testRequestBody("Made.Up.Request"))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+type unencodableDirective struct{}
+
+func (d *unencodableDirective) DirectiveType() string { return "Unencodable" }
+
+func (d *unencodableDirective) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("cannot encode")
+}
+
+func TestDefaultResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		launch func(*EchoRequest, *EchoResponse)
+	}{
+		{"no-op handler", func(req *EchoRequest, resp *EchoResponse) {}},
+		{"unencodable response", func(req *EchoRequest, resp *EchoResponse) {
+			resp.OutputSpeech("Hello").AddDirective(&unencodableDirective{})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := testAppHandler(t, EchoApplication{OnLaunch: tt.launch})
+
+			w := postTestEcho(h, testRequestBody("LaunchRequest"))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+
+			var body struct {
+				Version  string                     `json:"version"`
+				Response map[string]json.RawMessage `json:"response"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("could not decode response %s: %v", w.Body.String(), err)
+			}
+			want := map[string]json.RawMessage{"shouldEndSession": json.RawMessage("true")}
+			if body.Version != "1.0" || !reflect.DeepEqual(body.Response, want) {
+				t.Errorf("body = %s, want an empty response ending the session", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestWriteEcho(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := WriteEcho(w, NewEchoResponse().OutputSpeech("Hello")); err != nil {
+		t.Fatalf("WriteEcho() error = %v", err)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json;charset=UTF-8" {
+		t.Errorf("Content-Type = %q, want %q", got, "application/json;charset=UTF-8")
+	}
+	if !strings.Contains(w.Body.String(), `"text":"Hello"`) {
+		t.Errorf("body = %s, want the response", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	if err := WriteEcho(w, NewEchoResponse().AddDirective(&unencodableDirective{})); err == nil {
+		t.Error("WriteEcho() of an unencodable response error = nil, want the encoding error")
+	}
+	if want, _ := NewEchoResponse().String(); w.Body.String() != string(want) {
+		t.Errorf("body = %s, want the default response %s", w.Body.String(), want)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := WriteJSON(w, map[string]string{"status": "ok"}); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json;charset=UTF-8" || w.Body.String() != `{"status":"ok"}` {
+		t.Errorf("response = %q %s, want the JSON", got, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	if err := WriteJSON(w, make(chan int)); err == nil {
+		t.Error("WriteJSON() of a channel error = nil, want the encoding error")
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestListenAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		want    string
+	}{
+		{"all interfaces", nil, ":8080"},
+		{"IPv4 loopback", []Option{WithListenAddress("127.0.0.1")}, "127.0.0.1:8080"},
+		{"IPv6 loopback", []Option{WithListenAddress("::1")}, "[::1]:8080"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newConfigurator(tt.options).newServer("8080", nil).Addr; got != tt.want {
+				t.Errorf("Addr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// The address is usable for binding the server.
+	srv := newConfigurator([]Option{WithListenAddress("127.0.0.1")}).newServer("0", nil)
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		t.Fatalf("Listen(%q) error = %v", srv.Addr, err)
+	}
+	defer ln.Close()
+	if addr := ln.Addr().(*net.TCPAddr); !addr.IP.IsLoopback() {
+		t.Errorf("server bound to %v, want a loopback address", addr)
+	}
+}
+
+func TestHandlerWithHTTPTestServer(t *testing.T) {
+	server := httptest.NewServer(testHandler(t, WithValidator(NoopValidator())))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/echo/test", "application/json", strings.NewReader(testRequestBody("LaunchRequest")))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	var echoResp EchoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&echoResp); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || echoResp.GetOutputSpeech() == nil || echoResp.GetOutputSpeech().Text != "Hello" {
+		t.Errorf("response = %d %+v, want 200 with the launch speech", resp.StatusCode, echoResp.Response)
+	}
+
+	// StdApplications are served by the same handler.
+	page, err := http.Get(server.URL + "/url")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer page.Body.Close()
+	if page.StatusCode != http.StatusOK {
+		t.Errorf("status of the StdApplication = %d, want %d", page.StatusCode, http.StatusOK)
+	}
+}
+
+func TestPrefixConflicts(t *testing.T) {
+	page := StdApplication{Methods: "GET", Handler: func(w http.ResponseWriter, r *http.Request) {}}
+	echo := EchoApplication{AppID: testAppID}
+
+	tests := []struct {
+		name    string
+		apps    map[string]interface{}
+		options []Option
+		want    error
+	}{
+		{"default prefixes", testApps(), nil, nil},
+		{"root prefix within echo prefix", testApps(), []Option{WithRootPrefix("/echo/pages/")}, ErrPrefixConflict},
+		{"page within echo prefix", map[string]interface{}{"/echo/test": echo, "/echo/page": page}, nil, ErrPrefixConflict},
+		{"page within custom echo prefix", map[string]interface{}{"/alexa/test": echo, "/alexa/page": page}, []Option{WithEchoPrefix("/alexa/")}, ErrPrefixConflict},
+		{"echo prefix within root prefix", map[string]interface{}{"/echo/test": echo, "/page": page}, []Option{WithRootPrefix("/")}, nil},
+		{"no pages", map[string]interface{}{"/echo/test": echo}, []Option{WithRootPrefix("/echo/pages/")}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Handler(tt.apps, tt.options...)
+			if tt.want == nil && err != nil {
+				t.Errorf("Handler() error = %v, want nil", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Handler() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppIDRouter(t *testing.T) {
+	const secondAppID = "amzn1.ask.skill.second"
+	router := AppIDRouter{}
+	router.RegisterByAppID(testAppID, EchoApplication{OnLaunch: func(req *EchoRequest, resp *EchoResponse) {
+		resp.OutputSpeech("first")
+	}})
+	router.RegisterByAppID(secondAppID, EchoApplication{OnLaunch: func(req *EchoRequest, resp *EchoResponse) {
+		resp.OutputSpeech("second")
+	}})
+
+	tests := []struct {
+		name     string
+		appID    string
+		want     int
+		wantBody string
+	}{
+		{"first skill", testAppID, http.StatusOK, `"text":"first"`},
+		{"second skill", secondAppID, http.StatusOK, `"text":"second"`},
+		{"unknown skill", "amzn1.ask.skill.unknown", http.StatusNotFound, `{"error":"Not Found"}`},
+	}
+
+	registrations := []struct {
+		name   string
+		router interface{}
+	}{
+		{"value", router},
+		{"pointer", &router},
+	}
+
+	for _, registration := range registrations {
+		h, err := Handler(map[string]interface{}{"/echo/test": registration.router}, WithValidator(NoopValidator()))
+		if err != nil {
+			t.Fatalf("Handler() error = %v", err)
+		}
+
+		for _, tt := range tests {
+			t.Run(registration.name+"/"+tt.name, func(t *testing.T) {
+				body := strings.Replace(testRequestBody("LaunchRequest"), testAppID, tt.appID, -1)
+
+				w := postTestEcho(h, body)
+				if w.Code != tt.want {
+					t.Errorf("status = %d, want %d", w.Code, tt.want)
+				}
+				if !strings.Contains(w.Body.String(), tt.wantBody) {
+					t.Errorf("body = %s, want %s", w.Body.String(), tt.wantBody)
+				}
+			})
+		}
+	}
+}
+
+func TestServerTimeouts(t *testing.T) {
+	custom := ServerTimeouts{ReadHeader: time.Second, Read: 2 * time.Second, Write: 3 * time.Second, Idle: 4 * time.Second}
+
+	tests := []struct {
+		name    string
+		options []Option
+		want    ServerTimeouts
+	}{
+		{"defaults", nil, DefaultServerTimeouts},
+		{"configured", []Option{WithServerTimeouts(custom)}, custom},
+		{"disabled", []Option{WithServerTimeouts(ServerTimeouts{})}, ServerTimeouts{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newConfigurator(tt.options).newServer("8080", nil)
+
+			got := ServerTimeouts{ReadHeader: srv.ReadHeaderTimeout, Read: srv.ReadTimeout, Write: srv.WriteTimeout, Idle: srv.IdleTimeout}
+			if got != tt.want {
+				t.Errorf("server timeouts = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Config sets the same timeouts.
+	srv := newConfigurator(Config{Timeouts: &custom}.options()).newServer("8080", nil)
+	if srv.WriteTimeout != custom.Write || srv.IdleTimeout != custom.Idle {
+		t.Errorf("server timeouts from Config = %v, %v, want %v, %v", srv.WriteTimeout, srv.IdleTimeout, custom.Write, custom.Idle)
+	}
+}
+
+func TestGetEchoApplication(t *testing.T) {
+	var got EchoApplication
+	var ok bool
+	h := testAppHandler(t, EchoApplication{
+		AppID: testAppID,
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			got, ok = GetEchoApplication(r)
+			WriteEcho(w, NewEchoResponse())
+		},
+	})
+
+	if w := postTestEcho(h, testRequestBody("LaunchRequest")); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if !ok || got.AppID != testAppID {
+		t.Errorf("GetEchoApplication() = %q, %v, want the application %q", got.AppID, ok, testAppID)
+	}
+
+	if _, ok := GetEchoApplication(httptest.NewRequest("GET", "/", nil)); ok {
+		t.Error("GetEchoApplication() outside the echo pipeline = true, want false")
+	}
+}
+
+func TestRequestAttributes(t *testing.T) {
+	interceptor := WithRequestInterceptor(func(req *EchoRequest) {
+		req.RequestAttributes()["name"] = "Ada"
+	})
+
+	t.Run("OnLaunch", func(t *testing.T) {
+		h := testAppHandler(t, EchoApplication{
+			OnLaunch: func(req *EchoRequest, resp *EchoResponse) {
+				resp.OutputSpeech("Hello " + req.RequestAttributes()["name"].(string))
+			},
+		}, interceptor)
+
+		w := postTestEcho(h, testRequestBody("LaunchRequest"))
+		if !strings.Contains(w.Body.String(), `"text":"Hello Ada"`) {
+			t.Errorf("body = %s, want the speech using the request attribute", w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), `"name"`) {
+			t.Errorf("body = %s, want the request attributes not to be sent", w.Body.String())
+		}
+	})
+
+	t.Run("Handler", func(t *testing.T) {
+		var got interface{}
+		h := testAppHandler(t, EchoApplication{
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				got = GetRequestAttributes(r)["name"]
+				WriteEcho(w, NewEchoResponse())
+			},
+		}, interceptor)
+
+		postTestEcho(h, testRequestBody("LaunchRequest"))
+		if got != "Ada" {
+			t.Errorf("GetRequestAttributes()[name] = %v, want Ada", got)
+		}
+	})
+}
+
+func TestEchoErrorsAreJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		h    http.Handler
+		body string
+		want int
+	}{
+		{"validation failure", testHandler(t), testRequestBody("LaunchRequest"), http.StatusUnauthorized},
+		{"invalid JSON", testHandler(t, WithValidator(NoopValidator())), "{", http.StatusBadRequest},
+		{"app ID mismatch", testHandler(t, WithValidator(NoopValidator())), strings.Replace(testRequestBody("LaunchRequest"), testAppID, "amzn1.ask.skill.other", -1), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postTestEcho(tt.h, tt.body)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/json") {
+				t.Errorf("Content-Type = %q, want JSON", got)
+			}
+
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Error == "" {
+				t.Errorf("body = %s, want a JSON error", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestInvalidBodiesAreRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"empty body", "", `{"error":"Bad Request: empty body"}`},
+		{"invalid JSON", "{", `{"error":"Bad Request: invalid JSON"}`},
+		{"not an Alexa request", `{"name":"webhook"}`, `{"error":"Bad Request: not an Alexa request"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postTestEcho(testHandler(t, WithValidator(NoopValidator())), tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigHandler(t *testing.T) {
+	config := Config{
+		Apps: map[string]interface{}{
+			"/alexa/test": testApps()["/echo/test"],
+			"/web/url":    testApps()["/url"],
+		},
+		EchoPrefix: "/alexa/",
+		RootPrefix: "/web/",
+		Validator:  NoopValidator(),
+		Options:    []Option{WithRecoverySpeech("Oops.")},
+	}
+
+	h, err := config.Handler()
+	if err != nil {
+		t.Fatalf("Handler() error = %v", err)
+	}
+
+	w := serveTest(h, httptest.NewRequest("POST", "/alexa/test", strings.NewReader(testRequestBody("LaunchRequest"))))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"text":"Hello"`) {
+		t.Errorf("echo response = %d %s, want the launch speech", w.Code, w.Body.String())
+	}
+
+	w = serveTest(h, httptest.NewRequest("GET", "http://example.com/web/url", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "http://example.com/callback" {
+		t.Errorf("page response = %d %q, want the external URL", w.Code, w.Body.String())
+	}
+
+	// The default echo prefix is not served.
+	w = serveTest(h, httptest.NewRequest("POST", "/echo/test", strings.NewReader(testRequestBody("LaunchRequest"))))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status on the default prefix = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestServersWithDifferentPrefixes(t *testing.T) {
+	prefixes := []string{"/first/", "/second/"}
+	handlers := make([]http.Handler, len(prefixes))
+	for i, prefix := range prefixes {
+		apps := map[string]interface{}{prefix + "test": testApps()["/echo/test"]}
+		h, err := Handler(apps, WithEchoPrefix(prefix), WithValidator(NoopValidator()))
+		if err != nil {
+			t.Fatalf("Handler(%s) error = %v", prefix, err)
+		}
+		handlers[i] = h
+	}
+
+	var wg sync.WaitGroup
+	for i := range handlers {
+		for j, prefix := range prefixes {
+			// Each server only serves its own prefix.
+			want := http.StatusNotFound
+			if i == j {
+				want = http.StatusOK
+			}
+
+			wg.Add(1)
+			go func(h http.Handler, path string, want int) {
+				defer wg.Done()
+
+				w := serveTest(h, httptest.NewRequest("POST", path, strings.NewReader(testRequestBody("LaunchRequest"))))
+				if w.Code != want {
+					t.Errorf("status of %s = %d, want %d", path, w.Code, want)
+				}
+			}(handlers[i], prefix+"test", want)
+		}
+	}
+	wg.Wait()
+
+	// The package level prefix is left untouched.
+	if echoPrefix != "/echo/" {
+		t.Errorf("package echo prefix = %q, want %q", echoPrefix, "/echo/")
+	}
+}
+
+func TestStaticResponses(t *testing.T) {
+	h := testAppHandler(t, EchoApplication{
+		StaticResponses: map[string]*EchoResponse{
+			"LaunchRequest":     NewEchoResponse().OutputSpeech("Welcome to the demo."),
+			"IntentRequest":     NewEchoResponse().OutputSpeech("Any intent."),
+			"AMAZON.HelpIntent": NewEchoResponse().OutputSpeech("Say hello."),
+		},
+	})
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"request type", testRequestBody("LaunchRequest"), `"text":"Welcome to the demo."`},
+		{"intent name", testRequestBodyWith("IntentRequest", `"intent": {"name": "AMAZON.HelpIntent"}`), `"text":"Say hello."`},
+		{"intent without own response", testRequestBodyWith("IntentRequest", `"intent": {"name": "OtherIntent"}`), `"text":"Any intent."`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postTestEcho(h, tt.body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlersTakePrecedenceOverStaticResponses(t *testing.T) {
+	h := testAppHandler(t, EchoApplication{
+		OnLaunch: func(req *EchoRequest, resp *EchoResponse) {
+			resp.OutputSpeech("From the handler.")
+		},
+		StaticResponses: map[string]*EchoResponse{"LaunchRequest": NewEchoResponse().OutputSpeech("Static.")},
+	})
+
+	if w := postTestEcho(h, testRequestBody("LaunchRequest")); !strings.Contains(w.Body.String(), `"text":"From the handler."`) {
+		t.Errorf("body = %s, want the speech of the handler", w.Body.String())
+	}
+}
+
+func TestSlotValidators(t *testing.T) {
+	intentCalled := false
+	app := EchoApplication{
+		OnIntent: func(req *EchoRequest, resp *EchoResponse) {
+			intentCalled = true
+			resp.OutputSpeech("Booked.")
+		},
+		SlotValidators: map[string]func(string) error{
+			"travelers": func(value string) error {
+				if value == "0" {
+					return errors.New("How many people are traveling?")
+				}
+				return nil
+			},
+		},
+	}
+	intent := func(travelers string) string {
+		return testRequestBodyWith("IntentRequest", `"dialogState": "IN_PROGRESS", "intent": {"name": "BookTrip", "slots": {
+			"city": {"name": "city", "value": "Berlin"},
+			"travelers": {"name": "travelers", "value": "`+travelers+`"}
+		}}`)
+	}
+
+	w := postTestEcho(testAppHandler(t, app), intent("0"))
+	if intentCalled {
+		t.Error("OnIntent was called for an invalid slot value")
+	}
+
+	var resp struct {
+		Response struct {
+			OutputSpeech     EchoRespPayload `json:"outputSpeech"`
+			ShouldEndSession *bool           `json:"shouldEndSession"`
+			Directives       []struct {
+				Type          string     `json:"type"`
+				SlotToElicit  string     `json:"slotToElicit"`
+				UpdatedIntent EchoIntent `json:"updatedIntent"`
+			} `json:"directives"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("could not decode response %s: %v", w.Body.String(), err)
+	}
+	if got := resp.Response.OutputSpeech.Text; got != "How many people are traveling?" {
+		t.Errorf("speech = %q, want the error of the validator", got)
+	}
+	if len(resp.Response.Directives) != 1 {
+		t.Fatalf("directives = %s, want a single ElicitSlot directive", w.Body.String())
+	}
+	d := resp.Response.Directives[0]
+	if d.Type != "Dialog.ElicitSlot" || d.SlotToElicit != "travelers" {
+		t.Errorf("directive = %+v, want to elicit the travelers slot", d)
+	}
+	if d.UpdatedIntent.Slots["travelers"].Value != "" || d.UpdatedIntent.Slots["city"].Value != "Berlin" {
+		t.Errorf("updated intent = %+v, want the invalid value cleared and the others kept", d.UpdatedIntent)
+	}
+	if flag := resp.Response.ShouldEndSession; flag == nil || *flag {
+		t.Errorf("shouldEndSession = %v, want false", flag)
+	}
+
+	// Valid values reach the handler.
+	w = postTestEcho(testAppHandler(t, app), intent("2"))
+	if !intentCalled || !strings.Contains(w.Body.String(), `"text":"Booked."`) {
+		t.Errorf("body = %s, want the response of OnIntent", w.Body.String())
+	}
+}
+
+func TestAudioPlayerResponsesAreNormalized(t *testing.T) {
+	h := testAppHandler(t, EchoApplication{
+		OnAudioPlayerState: func(req *EchoRequest, resp *EchoResponse) {
+			resp.OutputSpeech("Now playing.").SimpleCard("title", "content").Reprompt("Still there?").EndSession(false).
+				AddAPLRenderDocumentDirective("doc", json.RawMessage(`{}`), nil).
+				AddAudioPlayerPlayDirective(PlayEnqueue, AudioStream{URL: "https://example.com/next.mp3", Token: "next", ExpectedPreviousToken: "current"})
+		},
+	})
+
+	tests := []struct {
+		requestType string
+		want        []string
+	}{
+		{"AudioPlayer.PlaybackStarted", []string{AudioPlayerPlay}},
+		{"AudioPlayer.PlaybackStopped", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.requestType, func(t *testing.T) {
+			w := postTestEcho(h, testRequestBodyWith(tt.requestType, `"token": "current"`))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body.String())
+			}
+
+			var resp struct {
+				Response map[string]json.RawMessage `json:"response"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("could not decode response %s: %v", w.Body.String(), err)
+			}
+			for _, field := range []string{"outputSpeech", "card", "reprompt", "shouldEndSession"} {
+				if value, ok := resp.Response[field]; ok {
+					t.Errorf("%s = %s, want the field stripped", field, value)
+				}
+			}
+
+			var directives []struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal(resp.Response["directives"], &directives)
+			var types []string
+			for _, d := range directives {
+				types = append(types, d.Type)
+			}
+			if !reflect.DeepEqual(types, tt.want) {
+				t.Errorf("directives = %v, want %v", types, tt.want)
+			}
+		})
+	}
+}
+
+func TestEchoRequestContextKey(t *testing.T) {
+	ours, theirs := &EchoRequest{}, &EchoRequest{}
+	ours.Request.RequestID, theirs.Request.RequestID = "ours", "theirs"
+
+	// Other middleware storing values under plain keys equal to the underlying value or name of the key.
+	ctx := context.WithValue(context.Background(), "echoRequest", theirs)
+	ctx = context.WithValue(ctx, int(echoRequestKey), theirs)
+	ctx = WithEchoRequest(ctx, ours)
+	ctx = context.WithValue(ctx, "echoRequestKey", theirs)
+
+	if got, ok := EchoRequestFromContext(ctx); !ok || got != ours {
+		t.Errorf("EchoRequestFromContext() = %v, %v, want the stored request", got, ok)
+	}
+	for _, key := range []interface{}{"echoRequest", "echoRequestKey", int(echoRequestKey)} {
+		if got := ctx.Value(key); got != theirs {
+			t.Errorf("Value(%#v) = %v, want the value of the other middleware", key, got)
+		}
+	}
+
+	if _, ok := EchoRequestFromContext(context.Background()); ok {
+		t.Error("EchoRequestFromContext() of an empty context = true, want false")
+	}
+	if _, ok := EchoRequestFromContext(WithEchoRequest(context.Background(), nil)); ok {
+		t.Error("EchoRequestFromContext() of a nil request = true, want false")
+	}
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	const n = 3
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := testAppHandler(t, EchoApplication{
+		OnLaunch: func(req *EchoRequest, resp *EchoResponse) {
+			started <- struct{}{}
+			<-release
+		},
+	}, WithMaxConcurrency(n))
+
+	codes := make(chan int, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			codes <- postTestEcho(h, testRequestBody("LaunchRequest")).Code
+		}()
+	}
+	for i := 0; i < n; i++ {
+		<-started
+	}
+
+	// All slots are taken by the blocked requests.
+	if w := postTestEcho(h, testRequestBody("LaunchRequest")); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status of request %d = %d, want %d", n+1, w.Code, http.StatusServiceUnavailable)
+	}
+
+	close(release)
+	for i := 0; i < n; i++ {
+		if code := <-codes; code != http.StatusOK {
+			t.Errorf("status of an admitted request = %d, want %d", code, http.StatusOK)
+		}
+	}
+
+	// The slots are free again.
+	go func() { <-started }()
+	if w := postTestEcho(h, testRequestBody("LaunchRequest")); w.Code != http.StatusOK {
+		t.Errorf("status after the requests finished = %d, want %d", w.Code, http.StatusOK)
+	}
+}
@@ -2,7 +2,11 @@ package skillserver
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"html/template"
+	"net/url"
+	"strings"
 )
 
 /**
@@ -182,3 +186,59 @@ func (builder *SSMLTextBuilder) AppendPhoneme(alphabet PhoneticAlphabet, phoneme
 func (builder *SSMLTextBuilder) Build() string {
 	return fmt.Sprintf("<speak>%s</speak>", builder.buffer.String())
 }
+
+// SpeakTemplate will execute the template with the data and set the result as SSML output speech. The
+// template is an html/template, so values inserted from data are escaped and user provided text containing
+// characters like `<` or `&` can't break the markup. The result is wrapped in a speak tag unless the
+// template contains one.
+func (r *EchoResponse) SpeakTemplate(tmpl *template.Template, data interface{}) error {
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return err
+	}
+
+	ssml := strings.TrimSpace(buffer.String())
+	if !strings.HasPrefix(ssml, "<speak>") {
+		ssml = fmt.Sprintf("<speak>%s</speak>", ssml)
+	}
+	r.OutputSpeechSSML(ssml)
+
+	return nil
+}
+
+var ssmlEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&quot;",
+	"'", "&apos;",
+)
+
+// EscapeSSML escapes the characters with a special meaning in SSML, so the text can safely be inserted
+// into SSML markup, e.g. as the content of a tag or the value of an attribute. Use it for text derived
+// from user input or external data that is passed to `OutputSpeechSSML`.
+func EscapeSSML(s string) string {
+	return ssmlEscaper.Replace(s)
+}
+
+// ErrUnsupportedAudioFormat is returned by `PlayAudio` for audio files that are not MP3 files.
+var ErrUnsupportedAudioFormat = errors.New("audio file must be an mp3")
+
+// PlayAudio will set the output speech to SSML playing the audio file at the URL, e.g. a short sound effect.
+// The file has to be an MP3 served over https and the path of the URL has to end in ".mp3". Amazon further
+// limits the length, bit rate and sample rate of the file, see the SSML reference. Use the AudioPlayer
+// interface for longer audio.
+func (r *EchoResponse) PlayAudio(audioURL string) error {
+	if err := requireHTTPS(audioURL); err != nil {
+		return err
+	}
+
+	link, _ := url.Parse(audioURL)
+	if !strings.HasSuffix(strings.ToLower(link.Path), ".mp3") {
+		return fmt.Errorf("%w: %q", ErrUnsupportedAudioFormat, audioURL)
+	}
+
+	r.OutputSpeechSSML(NewSSMLTextBuilder().AppendAudio(EscapeSSML(audioURL)).Build())
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package skillserver
+
+import (
+	"encoding/xml"
+	"errors"
+	"html/template"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Fails the test unless the SSML is well-formed XML.
+func checkWellFormedSSML(t *testing.T, ssml string) {
+	t.Helper()
+
+	decoder := xml.NewDecoder(strings.NewReader(ssml))
+	for {
+		_, err := decoder.Token()
+		if err != nil {
+			if err != io.EOF {
+				t.Errorf("SSML %q is not well-formed: %v", ssml, err)
+			}
+			return
+		}
+	}
+}
+
+func TestSpeakTemplateEscapesData(t *testing.T) {
+	tmpl := template.Must(template.New("greeting").Parse(`Hello <emphasis level="strong">{{.}}</emphasis>!`))
+
+	resp := NewEchoResponse()
+	if err := resp.SpeakTemplate(tmpl, "Tom & Jerry <3"); err != nil {
+		t.Fatalf("SpeakTemplate() error = %v", err)
+	}
+
+	ssml := resp.Response.OutputSpeech.SSML
+	want := `<speak>Hello <emphasis level="strong">Tom &amp; Jerry &lt;3</emphasis>!</speak>`
+	if resp.Response.OutputSpeech.Type != "SSML" || ssml != want {
+		t.Errorf("output speech = %+v, want SSML %q", resp.Response.OutputSpeech, want)
+	}
+	checkWellFormedSSML(t, ssml)
+}
+
+func TestEscapeSSML(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Tom & Jerry", "Tom &amp; Jerry"},
+		{"1 < 2", "1 &lt; 2"},
+		{"2 > 1", "2 &gt; 1"},
+		{`say "cheese"`, "say &quot;cheese&quot;"},
+		{"rock 'n' roll", "rock &apos;n&apos; roll"},
+		{"plain text", "plain text"},
+	}
+
+	for _, tt := range tests {
+		got := EscapeSSML(tt.in)
+		if got != tt.want {
+			t.Errorf("EscapeSSML(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		checkWellFormedSSML(t, `<speak><sub alias="`+got+`">`+got+`</sub></speak>`)
+	}
+}
+
+func TestPlayAudio(t *testing.T) {
+	resp := NewEchoResponse()
+	if err := resp.PlayAudio("https://example.com/sounds/bell.mp3?a=1&b=2"); err != nil {
+		t.Fatalf("PlayAudio() error = %v", err)
+	}
+
+	ssml := resp.Response.OutputSpeech.SSML
+	if want := `<speak><audio src="https://example.com/sounds/bell.mp3?a=1&amp;b=2"/></speak>`; ssml != want {
+		t.Errorf("SSML = %q, want %q", ssml, want)
+	}
+	checkWellFormedSSML(t, ssml)
+}
+
+func TestPlayAudioRejectsInvalidURLs(t *testing.T) {
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"http://example.com/sounds/bell.mp3", ErrInsecureURL},
+		{"https://example.com/sounds/bell.wav", ErrUnsupportedAudioFormat},
+	}
+
+	for _, tt := range tests {
+		resp := NewEchoResponse()
+		if err := resp.PlayAudio(tt.url); !errors.Is(err, tt.want) {
+			t.Errorf("PlayAudio(%q) error = %v, want %v", tt.url, err, tt.want)
+		}
+		if resp.Response.OutputSpeech != nil {
+			t.Errorf("PlayAudio(%q) set the output speech %+v", tt.url, resp.Response.OutputSpeech)
+		}
+	}
+}